@@ -72,9 +72,9 @@ speechly annotate --app <app_id> --reference-date 2021-01-20 --input input.txt >
 			log.Fatalf("Missing de-annotated flag: %s", err)
 		}
 
-		res, annotated, err := runThroughWLU(ctx, appId, inputFile, deAnnotate, refD)
+		wluOpts, err := readWLUOptions(cmd)
 		if err != nil {
-			log.Fatalf("WLU failed: %s", err)
+			log.Fatalf("Invalid WLU options: %s", err)
 		}
 
 		evaluate, err := cmd.Flags().GetBool("evaluate")
@@ -82,8 +82,13 @@ speechly annotate --app <app_id> --reference-date 2021-01-20 --input input.txt >
 			log.Fatalf("Missing evaluate flag: %s", err)
 		}
 
+		res, annotated, err := runThroughWLU(ctx, appId, inputFile, deAnnotate, evaluate, refD, wluOpts)
+		if err != nil {
+			log.Fatalf("WLU failed: %s", err)
+		}
+
 		if evaluate {
-			evaluateAnnotatedUtterances(wluResponsesToString(res.Responses), annotated, false)
+			evaluateAnnotatedUtterances(wluResponsesToString(res.Responses), wluResponsesToSegments(res.Responses), annotated, nluMatching{})
 			os.Exit(0)
 		}
 
@@ -113,6 +118,7 @@ func init() {
 	annotateCmd.Flags().StringP("reference-date", "r", "", "Reference date in YYYY-MM-DD format, if not provided use current date.")
 	annotateCmd.Flags().BoolP("de-annotate", "d", false, "Instead of adding annotation, remove annotations from output.")
 	annotateCmd.Flags().BoolP("evaluate", "e", false, "Print evaluation stats instead of the annotated output.")
+	addWLUFlags(annotateCmd)
 }
 
 func printEvalResultTXT(out io.Writer, items []*wluv1.WLUResponse) error {
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+
+	"github.com/speechly/cli/pkg/augment"
+)
+
+// ConditionScore is the word error rate of the corpus under a single augmentation condition.
+type ConditionScore struct {
+	WER    float64 `json:"wer"`
+	Errors int     `json:"errors"`
+	Words  int     `json:"words"`
+}
+
+func readConditions(specs []string) ([]augment.Condition, error) {
+	conditions := make([]augment.Condition, 0, len(specs))
+	for _, s := range specs {
+		c, err := augment.ParseCondition(s)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+// sampleScale maps 16-bit samples to [-1, 1).
+const sampleScale = 32768
+
+func readSamples(fn string) ([]float64, error) {
+	var samples []float64
+	err := readAudio(fn, AudioCorpusItem{}, func(buffer audio.IntBuffer, n int) error {
+		for _, x := range buffer.Data[:n] {
+			samples = append(samples, float64(x)/sampleScale)
+		}
+		return nil
+	})
+	return samples, err
+}
+
+func writeSamples(fn string, samples []float64) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	data := make([]int, len(samples))
+	for i, x := range samples {
+		v := int(math.Round(x * sampleScale))
+		if v > sampleScale-1 {
+			v = sampleScale - 1
+		} else if v < -sampleScale {
+			v = -sampleScale
+		}
+		data[i] = v
+	}
+	enc := wav.NewEncoder(f, streamSampleRate, 16, 1, 1)
+	err = enc.Write(&audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: 1, SampleRate: streamSampleRate},
+		Data:           data,
+		SourceBitDepth: 16,
+	})
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readNoises reads the wav files in the directory.
+func readNoises(dir string) ([][]float64, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no wav files found in %s", dir)
+	}
+	noises := make([][]float64, len(files))
+	for i, fn := range files {
+		if noises[i], err = readSamples(fn); err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+	}
+	return noises, nil
+}
+
+// augmentCorpus writes the perturbed audio of the corpus items and a corpus file referring to them into
+// dir, and returns the path of the corpus file.
+func augmentCorpus(corpusPath string, ac []AudioCorpusItem, c augment.Condition, a *augment.Augmenter, dir string) (string, error) {
+	out := filepath.Join(dir, "corpus.jsonl")
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	enc := json.NewEncoder(f)
+	for i, aci := range ac {
+		samples, err := readSamples(corpusAudioPath(corpusPath, aci))
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", aci.Audio, err)
+		}
+		perturbed, err := a.Apply(c, samples)
+		if err != nil {
+			return "", err
+		}
+		fn := fmt.Sprintf("%05d.wav", i)
+		if err := writeSamples(filepath.Join(dir, fn), perturbed); err != nil {
+			return "", err
+		}
+		if err := enc.Encode(AudioCorpusItem{Audio: fn, Transcript: aci.Transcript, Transcripts: aci.Transcripts}); err != nil {
+			return "", err
+		}
+	}
+	return out, f.Close()
+}
+
+// evaluateConditions transcribes the corpus under each condition and computes the word error rates. The
+// perturbed audio of a condition is deleted once it has been transcribed.
+func evaluateConditions(corpusPath string, conditions []augment.Condition, a *augment.Augmenter, scoring asrScoring, transcribe func(string) ([]AudioCorpusItem, error)) (map[string]ConditionScore, error) {
+	ac, err := readAudioCorpus(corpusPath)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[string]ConditionScore)
+	for _, c := range conditions {
+		dir, err := os.MkdirTemp("", "speechly-augment-")
+		if err != nil {
+			return nil, err
+		}
+		results, err := func() ([]AudioCorpusItem, error) {
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+			augmented, err := augmentCorpus(corpusPath, ac, c, a, dir)
+			if err != nil {
+				return nil, err
+			}
+			return transcribe(augmented)
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c, err)
+		}
+		ed, err := corpusErrors(results, scoring)
+		if err != nil {
+			return nil, err
+		}
+		scores[c.String()] = ConditionScore{WER: ed.AsER(), Errors: ed.dist, Words: ed.base}
+	}
+	return scores, nil
+}
+
+func corpusErrors(ac []AudioCorpusItem, scoring asrScoring) (EditDistance, error) {
+	ed := EditDistance{}
+	for _, aci := range ac {
+		ref, err := bestReference(aci, asrScoring{annotated: scoring.annotated})
+		if err != nil {
+			return ed, fmt.Errorf("invalid ground truth of %s: %v", aci.Audio, err)
+		}
+		ed = ed.Add(ref.distance)
+	}
+	return ed, nil
+}
+
+func printConditions(out io.Writer, report EvaluationReport, conditions []augment.Condition) error {
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nCONDITION\tWER\tERRORS\tWORDS\n")
+	fmt.Fprintf(w, "clean\t%.4f\t%d\t%d\n", report.WER, report.Errors, report.Words)
+	for _, c := range conditions {
+		s := report.Conditions[c.String()]
+		fmt.Fprintf(w, "%s\t%.4f\t%d\t%d\n", c, s.WER, s.Errors, s.Words)
+	}
+	return w.Flush()
+}
+
+func newAugmenter(noiseDir string, seed int64) (*augment.Augmenter, error) {
+	a := &augment.Augmenter{SampleRate: streamSampleRate, Rand: rand.New(rand.NewSource(seed))}
+	if noiseDir != "" {
+		var err error
+		if a.Noises, err = readNoises(noiseDir); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
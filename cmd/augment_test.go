@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSamplesRoundTrip(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "samples.wav")
+	samples := []float64{-1.5, -1, -0.5, 0, 0.25, 32767.0 / 32768, 1, 2}
+	if err := writeSamples(fn, samples); err != nil {
+		t.Fatal(err)
+	}
+	result, err := readSamples(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{-1, -1, -0.5, 0, 0.25, 32767.0 / 32768, 32767.0 / 32768, 32767.0 / 32768}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
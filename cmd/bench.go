@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/speechly/cli/pkg/clients"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench <app_id> <input_file>",
+	Short: "Measure the latency of the Streaming API",
+	Long:  "Replays the audio of a corpus through the Streaming API at real-time pace (or faster with --speed) and measures:\n\n- first word latency: time from the first audio chunk to the first transcribed word\n- final latency: time from the end of the audio (SLUStop) to the end of the stream\n- stability: share of tentative words that were not revised later\n- throughput: seconds of audio processed per second\n\nThe input file is either a single wav file or a JSON Lines file of audio files, as in `transcribe`. Use --host to benchmark a different API host, e.g. a local server.",
+	Example: `speechly bench <app_id> audio.wav
+speechly bench <app_id> corpus.jsonl --speed 2
+speechly bench <app_id> corpus.jsonl --host localhost:9000 --max-first-word-latency 500ms --max-final-latency 1s`,
+	Args: cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		percentile, _ := cmd.Flags().GetFloat64("percentile")
+		if math.IsNaN(percentile) || percentile < 0 || percentile > 100 {
+			return fmt.Errorf("percentile must be between 0 and 100")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		appID := args[0]
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		chunk, _ := cmd.Flags().GetDuration("chunk")
+		percentile, _ := cmd.Flags().GetFloat64("percentile")
+		maxFirstWord, _ := cmd.Flags().GetDuration("max-first-word-latency")
+		maxFinal, _ := cmd.Flags().GetDuration("max-final-latency")
+		if speed < 0 || chunk <= 0 {
+			log.Fatalf("Speed must be non-negative and chunk duration positive")
+		}
+		if host, _ := cmd.Flags().GetString("host"); host != "" {
+			ctx = clients.WithHost(ctx, host)
+		}
+
+		ac, err := readAudioCorpus(args[1])
+		if err != nil {
+			log.Fatalf("Reading corpus failed: %v", err)
+		}
+		client, err := clients.SLUClient(ctx)
+		if err != nil {
+			log.Fatalf("Error connecting to API: %v", err)
+		}
+
+		chunkSamples := int(chunk.Seconds() * streamSampleRate)
+		bar := getBar("Benchmarking", "utt", len(ac))
+		var results []benchResult
+		start := time.Now()
+		for _, aci := range ac {
+			r, err := benchStream(ctx, client, appID, corpusAudioPath(args[1], aci), speed, chunkSamples)
+			if err != nil {
+				barClearOnError(bar)
+				log.Fatalf("Streaming %s failed: %v", aci.Audio, err)
+			}
+			results = append(results, r)
+			_ = bar.Add(1)
+		}
+		_ = bar.Close()
+
+		s := summarizeBench(results, time.Since(start))
+		if err := printBenchSummary(cmd.OutOrStdout(), s); err != nil {
+			log.Fatalf("Printing results failed: %v", err)
+		}
+
+		var failures []string
+		if maxFirstWord > 0 {
+			if p := durationPercentile(s.firstWord, percentile); p > maxFirstWord {
+				failures = append(failures, fmt.Sprintf("p%g first word latency %v is above the maximum %v", percentile, p, maxFirstWord))
+			}
+		}
+		if maxFinal > 0 {
+			if p := durationPercentile(s.final, percentile); p > maxFinal {
+				failures = append(failures, fmt.Sprintf("p%g final latency %v is above the maximum %v", percentile, p, maxFinal))
+			}
+		}
+		if len(failures) > 0 {
+			log.Println("Latency check failed")
+			for _, f := range failures {
+				log.Printf("└─ %s", f)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+// benchResult contains the measurements of a single stream.
+type benchResult struct {
+	audio     time.Duration
+	firstWord time.Duration
+	final     time.Duration
+	hasWords  bool
+	// tentative is the number of tentative words received and revised the number of them that changed
+	// in a later tentative or final result.
+	tentative int
+	revised   int
+}
+
+// benchRecorder records the arrival times of the responses of a stream.
+type benchRecorder struct {
+	mu        sync.Mutex
+	firstWord time.Time
+	finished  time.Time
+	tentative map[int32]map[int32]string
+	observed  int
+	revised   int
+}
+
+func (b *benchRecorder) add(res *sluv1.SLUResponse) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seg := b.tentative[res.GetSegmentId()]
+	if seg == nil {
+		seg = make(map[int32]string)
+		b.tentative[res.GetSegmentId()] = seg
+	}
+	switch v := res.StreamingResponse.(type) {
+	case *sluv1.SLUResponse_TentativeTranscript:
+		if len(v.TentativeTranscript.GetTentativeWords()) > 0 && b.firstWord.IsZero() {
+			b.firstWord = now
+		}
+		for _, w := range v.TentativeTranscript.GetTentativeWords() {
+			b.observed++
+			if prev, ok := seg[w.GetIndex()]; ok && prev != w.GetWord() {
+				b.revised++
+			}
+			seg[w.GetIndex()] = w.GetWord()
+		}
+	case *sluv1.SLUResponse_Transcript:
+		if b.firstWord.IsZero() {
+			b.firstWord = now
+		}
+		if prev, ok := seg[v.Transcript.GetIndex()]; ok && prev != v.Transcript.GetWord() {
+			b.revised++
+		}
+		delete(seg, v.Transcript.GetIndex())
+	case *sluv1.SLUResponse_Finished:
+		b.finished = now
+	}
+}
+
+func benchStream(ctx context.Context, client sluv1.SLUClient, appID string, audioFilePath string, speed float64, chunkSamples int) (benchResult, error) {
+	pace := &audioPace{speed: speed, chunkSamples: chunkSamples}
+	rec := &benchRecorder{tentative: make(map[int32]map[int32]string)}
+	if err := streamAudio(ctx, client, appID, audioFilePath, pace, rec.add); err != nil {
+		return benchResult{}, err
+	}
+	closed := time.Now()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	r := benchResult{
+		audio:     pace.audioDuration(),
+		tentative: rec.observed,
+		revised:   rec.revised,
+	}
+	if !rec.firstWord.IsZero() {
+		r.hasWords = true
+		r.firstWord = rec.firstWord.Sub(pace.audioStart)
+	}
+	finished := rec.finished
+	if finished.IsZero() {
+		finished = closed
+	}
+	if r.final = finished.Sub(pace.stopSent); r.final < 0 {
+		r.final = 0
+	}
+	return r, nil
+}
+
+// benchSummary aggregates the results of all streams.
+type benchSummary struct {
+	streams   int
+	audio     time.Duration
+	elapsed   time.Duration
+	firstWord []time.Duration
+	final     []time.Duration
+	tentative int
+	revised   int
+}
+
+func summarizeBench(results []benchResult, elapsed time.Duration) benchSummary {
+	s := benchSummary{streams: len(results), elapsed: elapsed}
+	for _, r := range results {
+		s.audio += r.audio
+		if r.hasWords {
+			s.firstWord = append(s.firstWord, r.firstWord)
+		}
+		s.final = append(s.final, r.final)
+		s.tentative += r.tentative
+		s.revised += r.revised
+	}
+	sort.Slice(s.firstWord, func(i, j int) bool { return s.firstWord[i] < s.firstWord[j] })
+	sort.Slice(s.final, func(i, j int) bool { return s.final[i] < s.final[j] })
+	return s
+}
+
+// durationPercentile returns the nearest-rank percentile of the sorted durations.
+func durationPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func meanDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum / time.Duration(len(durations))
+}
+
+func printBenchSummary(out io.Writer, s benchSummary) error {
+	fmt.Fprintf(out, "\nStreams:    %d\n", s.streams)
+	fmt.Fprintf(out, "Audio:      %v\n", s.audio.Round(time.Millisecond))
+	fmt.Fprintf(out, "Elapsed:    %v\n", s.elapsed.Round(time.Millisecond))
+	if s.elapsed > 0 {
+		fmt.Fprintf(out, "Throughput: %.2f s of audio per s\n", s.audio.Seconds()/s.elapsed.Seconds())
+	}
+	if s.tentative > 0 {
+		fmt.Fprintf(out, "Stability:  %.4f (%d/%d tentative words revised)\n", 1-float64(s.revised)/float64(s.tentative), s.revised, s.tentative)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nLATENCY\tMEAN\tP50\tP90\tP95\tP99\tMAX\n")
+	for _, row := range []struct {
+		name   string
+		values []time.Duration
+	}{{"first word", s.firstWord}, {"final", s.final}} {
+		if len(row.values) == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\n", row.name)
+			continue
+		}
+		fmt.Fprintf(w, "%s", row.name)
+		fmt.Fprintf(w, "\t%v", meanDuration(row.values).Round(time.Millisecond))
+		for _, p := range []float64{50, 90, 95, 99} {
+			fmt.Fprintf(w, "\t%v", durationPercentile(row.values, p).Round(time.Millisecond))
+		}
+		fmt.Fprintf(w, "\t%v\n", row.values[len(row.values)-1].Round(time.Millisecond))
+	}
+	return w.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().Float64("speed", 1, "Audio replay speed relative to real time. 0 sends the audio as fast as possible.")
+	benchCmd.Flags().Duration("chunk", 100*time.Millisecond, "Duration of the audio sent in a single message.")
+	benchCmd.Flags().String("host", "", "API host to benchmark instead of the host of the current project, e.g. localhost:9000.")
+	benchCmd.Flags().Float64("percentile", 95, "Percentile the latency limits are checked against.")
+	benchCmd.Flags().Duration("max-first-word-latency", 0, "Fail if the first word latency percentile is above the given duration.")
+	benchCmd.Flags().Duration("max-final-latency", 0, "Fail if the final latency percentile is above the given duration.")
+}
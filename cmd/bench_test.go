@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+)
+
+func TestDurationPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	tests := []struct {
+		durations []time.Duration
+		p         float64
+		expected  time.Duration
+	}{
+		{sorted, 0, 10},
+		{sorted, 10, 10},
+		{sorted, 11, 20},
+		{sorted, 50, 50},
+		{sorted, 95, 100},
+		{sorted, 100, 100},
+		{[]time.Duration{5}, 50, 5},
+		{nil, 95, 0},
+	}
+	for _, test := range tests {
+		if p := durationPercentile(test.durations, test.p); p != test.expected {
+			t.Errorf("p%g of %v: expected %v, got %v", test.p, test.durations, test.expected, p)
+		}
+	}
+}
+
+func sluTentative(segment int32, words ...string) *sluv1.SLUResponse {
+	tentative := make([]*sluv1.SLUTranscript, len(words))
+	for i, w := range words {
+		tentative[i] = &sluv1.SLUTranscript{Word: w, Index: int32(i)}
+	}
+	return &sluv1.SLUResponse{SegmentId: segment, StreamingResponse: &sluv1.SLUResponse_TentativeTranscript{TentativeTranscript: &sluv1.SLUTentativeTranscript{TentativeWords: tentative}}}
+}
+
+func TestBenchRecorderAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*sluv1.SLUResponse
+		observed  int
+		revised   int
+		firstWord bool
+		finished  bool
+	}{
+		{
+			name:      "stable words",
+			responses: []*sluv1.SLUResponse{sluTentative(0, "turn"), sluTentative(0, "turn", "on"), sluWord(0, 0, "turn"), sluWord(0, 1, "on")},
+			observed:  3,
+			firstWord: true,
+		},
+		{
+			name:      "revised tentative and final words",
+			responses: []*sluv1.SLUResponse{sluTentative(0, "turn", "of"), sluTentative(0, "turn", "off", "the"), sluWord(0, 0, "turn"), sluWord(0, 1, "off"), sluWord(0, 2, "tv")},
+			observed:  5,
+			revised:   2,
+			firstWord: true,
+		},
+		{
+			name:      "segments are separate",
+			responses: []*sluv1.SLUResponse{sluTentative(0, "turn"), sluTentative(1, "stop"), sluWord(1, 0, "stop")},
+			observed:  2,
+			firstWord: true,
+		},
+		{
+			name:      "no words",
+			responses: []*sluv1.SLUResponse{sluTentative(0), sluIntent(0, "turn_on"), {StreamingResponse: &sluv1.SLUResponse_Finished{Finished: &sluv1.SLUFinished{}}}},
+			finished:  true,
+		},
+	}
+	for _, test := range tests {
+		b := &benchRecorder{tentative: make(map[int32]map[int32]string)}
+		for _, res := range test.responses {
+			b.add(res)
+		}
+		if b.observed != test.observed || b.revised != test.revised {
+			t.Errorf("%s: expected %d/%d revised, got %d/%d", test.name, test.revised, test.observed, b.revised, b.observed)
+		}
+		if b.firstWord.IsZero() == test.firstWord {
+			t.Errorf("%s: expected first word time to be set: %v", test.name, test.firstWord)
+		}
+		if b.finished.IsZero() == test.finished {
+			t.Errorf("%s: expected finish time to be set: %v", test.name, test.finished)
+		}
+	}
+}
@@ -2,17 +2,15 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path"
-	"regexp"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/go-audio/audio"
@@ -23,10 +21,9 @@ import (
 	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
 	wluv1 "github.com/speechly/api/go/speechly/slu/v1"
 	"github.com/speechly/cli/pkg/clients"
-	"github.com/speechly/nwalgo"
+	"github.com/speechly/cli/pkg/sal"
+	"github.com/speechly/cli/pkg/upload"
 	"github.com/spf13/cobra"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -52,6 +49,46 @@ func printLineErrors(messages []*salv1.LineReference) {
 	os.Exit(1)
 }
 
+// dryRunUsage is the usage of the --dry-run flag of the commands that upload a configuration directory.
+const dryRunUsage = "List the files that would be uploaded with their sizes, without uploading them. The *.yaml and *.csv files of the directory and its subdirectories are uploaded, with their paths relative to the directory, except paths matching the patterns of a .speechlyignore file in the directory, with the same syntax as .gitignore."
+
+// printUploadFiles lists the files that would be uploaded from the directory, with their sizes.
+func printUploadFiles(out io.Writer, inDir string) error {
+	files, err := upload.ListFiles(inDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files to upload from %s, please ensure the files are named *.yaml or *.csv", inDir)
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "FILE\tSIZE\n")
+	var total int64
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%d\n", f.Name, f.Size)
+		total += f.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n%d files, %d bytes in total\n", len(files), total)
+	return nil
+}
+
+// streamUploadData streams the tar package of the configuration files to w, showing the progress of the upload.
+// Returns the number of bytes written and the SHA-256 checksum of the package.
+func streamUploadData(ud upload.UploadData, w io.Writer) (int64, string, error) {
+	bar := getBar("Uploading   ", "bytes", int(ud.Size))
+	n, checksum, err := ud.Stream(w, bar)
+	if err != nil {
+		barClearOnError(bar)
+		return n, "", err
+	}
+	_ = bar.Finish()
+	log.Printf("Uploaded %d bytes, SHA-256 %s\n", n, checksum)
+	return n, checksum, nil
+}
+
 func waitForAppStatus(cmd *cobra.Command, configClient configv1.ConfigAPIClient, appId string, status configv1.App_Status) {
 	ctx := cmd.Context()
 
@@ -109,20 +146,16 @@ func readReferenceDate(cmd *cobra.Command) (time.Time, error) {
 	return refD, nil
 }
 
-func runThroughWLU(ctx context.Context, appID string, inputFile string, deAnnotate bool, refD time.Time) (*wluv1.TextsResponse, []string, error) {
+// runThroughWLU sends the lines of the input file, with their annotations removed, to WLU. With groundTruth,
+// the lines are ground truth and the ones that are not valid SAL are skipped. Returns the responses and the
+// lines they are for.
+func runThroughWLU(ctx context.Context, appID string, inputFile string, deAnnotate bool, groundTruth bool, refD time.Time, opts WLUOptions) (*wluv1.TextsResponse, []string, error) {
 	wluClient, err := clients.WLUClient(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	data := readLines(inputFile)
-
-	annotated := data
-	transcripts := make([]string, len(data))
-	for i, line := range data {
-		transcripts[i] = removeAnnotations(line)
-	}
-	data = transcripts
+	annotated, data := transcriptLines(readLines(inputFile), groundTruth)
 
 	if deAnnotate {
 		for _, line := range data {
@@ -138,30 +171,38 @@ func runThroughWLU(ctx context.Context, appID string, inputFile string, deAnnota
 			ReferenceTime: timestamppb.New(refD),
 		}
 	}
-	textsRequest := &wluv1.TextsRequest{
-		AppId:    appID,
-		Requests: wluRequests,
-	}
 
-	res, err := wluClient.Texts(ctx, textsRequest)
+	res, err := textsInBatches(ctx, wluClient, appID, wluRequests, opts)
 	return res, annotated, err
 }
 
-func removeAnnotations(line string) string {
-	removeNormalizedPattern := regexp.MustCompile(`\|.+?]\(([^)]+)\)`)
-	line = removeNormalizedPattern.ReplaceAllString(line, "]")
-
-	removablePattern := regexp.MustCompile(`\*([^ ]+)(?: |$)|\(([^)]+)\)`)
-	line = removablePattern.ReplaceAllString(line, "")
-
-	entityValuePattern := regexp.MustCompile(`\[([^]]+)]`)
-	return entityValuePattern.ReplaceAllStringFunc(line, func(s string) string {
-		pipeIndex := strings.Index(s, "|")
-		if pipeIndex == -1 {
-			pipeIndex = len(s) - 1
+// transcriptLines removes the annotations from the lines. With skipInvalid, the lines that are not valid SAL
+// are left out of both the returned lines and the transcripts, so that the two stay aligned. Otherwise such
+// lines are plain text and kept unchanged, so that each transcript matches an input line.
+func transcriptLines(lines []string, skipInvalid bool) ([]string, []string) {
+	kept := make([]string, 0, len(lines))
+	transcripts := make([]string, 0, len(lines))
+	for i, line := range lines {
+		transcript, err := removeAnnotations(line)
+		if err != nil {
+			if skipInvalid {
+				log.Printf("Skipping line %d: %v", i+1, err)
+				continue
+			}
+			transcript = line
 		}
-		return s[1:pipeIndex]
-	})
+		kept = append(kept, line)
+		transcripts = append(transcripts, transcript)
+	}
+	return kept, transcripts
+}
+
+func removeAnnotations(line string) (string, error) {
+	u, err := sal.Parse(line)
+	if err != nil {
+		return "", err
+	}
+	return u.Text(), nil
 }
 
 func readLines(fn string) []string {
@@ -191,7 +232,7 @@ func scanLines(file *os.File) []string {
 	return lines
 }
 
-func evaluateAnnotatedUtterances(annotatedData []string, groundTruthData []string, relaxed bool) {
+func evaluateAnnotatedUtterances(annotatedData []string, predicted [][]annotatedSegment, groundTruthData []string, matching nluMatching) EvaluationReport {
 	if len(annotatedData) != len(groundTruthData) {
 		log.Fatalf(
 			"Inputs should have same length, but input has %d items and ground-truths %d items.",
@@ -199,27 +240,50 @@ func evaluateAnnotatedUtterances(annotatedData []string, groundTruthData []strin
 			len(groundTruthData),
 		)
 	}
-	var entValRE = regexp.MustCompile(`\|[^]]+]`)
-	caser := cases.Lower(language.AmericanEnglish)
+	groundTruth, err := parseAnnotatedUtterances(groundTruthData)
+	if err != nil {
+		log.Fatalf("Invalid ground truth: %v", err)
+	}
 
+	report := EvaluationReport{
+		Type:     "nlu",
+		Total:    len(annotatedData),
+		Intents:  make(map[string]Score),
+		Entities: make(map[string]Score),
+	}
 	n := float64(len(annotatedData))
 	hits := 0.0
+	diff := newDiffRenderer()
 	for i, aUtt := range annotatedData {
 		gtUtt := groundTruthData[i]
-		if relaxed {
-			aUtt = entValRE.ReplaceAllString(caser.String(aUtt), "]")
-			gtUtt = entValRE.ReplaceAllString(caser.String(gtUtt), "]")
+		aUtt = matching.normalize(aUtt)
+		gtUtt = matching.normalize(gtUtt)
+		hit := strings.TrimSpace(aUtt) == strings.TrimSpace(gtUtt)
+		for _, seg := range groundTruth[i] {
+			if seg.intent != "" {
+				report.Intents[seg.intent] = report.Intents[seg.intent].add(hit)
+			}
+			for _, ent := range seg.entities {
+				report.Entities[ent.entityType] = report.Entities[ent.entityType].add(hit)
+			}
 		}
-		aln1, aln2, _ := nwalgo.Align(gtUtt, aUtt, "*", 1, -1, -1)
-		if strings.TrimSpace(aUtt) == strings.TrimSpace(gtUtt) {
+		if hit {
 			hits += 1.0
 			continue
 		}
 		fmt.Printf("\nLine: %d\n", i+1)
-		fmt.Printf("└─ Ground truth: %s\n", aln1)
-		fmt.Printf("└─ Prediction:   %s\n", aln2)
+		diff.print(os.Stdout, alignExact(strings.Fields(gtUtt), strings.Fields(aUtt)))
 	}
 	fmt.Printf("\nAccuracy: %.2f (%.0f/%.0f)\n", hits/n, hits, n)
+	report.Hits = int(hits)
+	report.Accuracy = hits / n
+
+	metrics := compareAnnotations(predicted, groundTruth, matching)
+	if err := printNLUMetrics(os.Stdout, metrics); err != nil {
+		log.Fatalf("Printing metrics failed: %v", err)
+	}
+	report.NLU = &metrics
+	return report
 }
 
 func wluResponsesToString(responses []*wluv1.WLUResponse) []string {
@@ -318,7 +382,7 @@ func transcribeWithBatchAPI(ctx context.Context, appID string, corpusPath string
 	}
 	bar := getBar("Uploading   ", "utt", len(ac))
 	for _, aci := range ac {
-		if requireGroundTruth && aci.Transcript == "" {
+		if requireGroundTruth && !aci.hasGroundTruth() {
 			barClearOnError(bar)
 			return nil, fmt.Errorf("missing ground truth")
 		}
@@ -411,11 +475,7 @@ func transcribeWithBatchAPI(ctx context.Context, appID string, corpusPath string
 				for i, tr := range trs {
 					words[i] = tr.Word
 				}
-				aci := AudioCorpusItem{
-					Audio:      aci.Audio,
-					Transcript: aci.Transcript,
-					Hypothesis: strings.Join(words, " "),
-				}
+				aci.Hypothesis = strings.Join(words, " ")
 				results = append(results, aci)
 
 				delete(pending, bID)
@@ -467,140 +527,35 @@ func getBar(desc string, unit string, inputSize int) *progressbar.ProgressBar {
 	return bar
 }
 
-func transcribeWithStreamingAPI(ctx context.Context, appID string, corpusPath string, requireGroundTruth bool) ([]AudioCorpusItem, error) {
-	trIdx := 0
-	var (
-		results     []AudioCorpusItem
-		audios      []string
-		transcripts []string
-	)
-
+// transcribeWithStreamingAPI transcribes the corpus items with up to parallelism concurrent streams. The
+// results are in the order of the corpus. On error, the results completed before the first failed item
+// are returned.
+func transcribeWithStreamingAPI(ctx context.Context, appID string, corpusPath string, requireGroundTruth bool, parallelism int) ([]AudioCorpusItem, error) {
 	ac, err := readAudioCorpus(corpusPath)
 	if err != nil {
 		return nil, err
 	}
-
-	bar := getBar("Transcribing", "utt", len(ac))
-	for _, aci := range ac {
-		client, err := clients.SLUClient(ctx)
-		if err != nil {
-			barClearOnError(bar)
-			return nil, err
-		}
-
-		stream, err := client.Stream(ctx)
-		if err != nil {
-			barClearOnError(bar)
-			return nil, err
-		}
-
-		done := make(chan error)
-		words := make([]string, 0)
-
-		go func() {
-			for {
-				res, err := stream.Recv()
-				if err != nil {
-					if err == io.EOF {
-						done <- nil
-					} else {
-						done <- err
-					}
-					return
-				}
-				switch r := res.StreamingResponse.(type) {
-				case *sluv1.SLUResponse_Started:
-				case *sluv1.SLUResponse_Finished:
-					aci := AudioCorpusItem{Audio: audios[trIdx], Transcript: transcripts[trIdx], Hypothesis: strings.Join(words, " ")}
-					results = append(results, aci)
-					trIdx++
-					words = make([]string, 0)
-				case *sluv1.SLUResponse_Transcript:
-					words = append(words, r.Transcript.Word)
-				case *sluv1.SLUResponse_Entity:
-				case *sluv1.SLUResponse_Intent:
-				}
+	if requireGroundTruth {
+		for _, aci := range ac {
+			if !aci.hasGroundTruth() {
+				return nil, fmt.Errorf("missing ground truth")
 			}
-		}()
-
-		err = stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Config{
-			Config: &sluv1.SLUConfig{
-				Encoding:        sluv1.SLUConfig_LINEAR16,
-				Channels:        1,
-				SampleRateHertz: 16000,
-				LanguageCode:    "en-US",
-			},
-		}})
-		if err != nil {
-			return nil, err
-		}
-
-		audios = append(audios, aci.Audio)
-		transcripts = append(transcripts, aci.Transcript)
-		err = stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Start{Start: &sluv1.SLUStart{
-			AppId: appID,
-		}}})
-		if err != nil {
-			return nil, err
-		}
-
-		audioFilePath := path.Join(path.Dir(corpusPath), aci.Audio)
-		if corpusPath == aci.Audio {
-			audioFilePath = corpusPath
-		}
-
-		err = readAudio(audioFilePath, aci, func(buffer audio.IntBuffer, n int) error {
-			buffer16 := make([]uint16, len(buffer.Data))
-			for i, x := range buffer.Data {
-				buffer16[i] = uint16(x)
-			}
-			buf := new(bytes.Buffer)
-			err = binary.Write(buf, binary.LittleEndian, buffer16)
-			if err != nil {
-				return err
-			}
-
-			err = stream.Send(&sluv1.SLURequest{
-				StreamingRequest: &sluv1.SLURequest_Audio{
-					Audio: buf.Bytes(),
-				},
-			})
-			if err != nil {
-				return err
-			}
-			return nil
-		})
-		err = stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Stop{Stop: &sluv1.SLUStop{}}})
-		if err != nil {
-			return nil, err
-		}
-		err = stream.CloseSend()
-		if err != nil {
-			return nil, err
-		}
-		if err != nil {
-			barClearOnError(bar)
-			return results, err
 		}
+	}
 
-		err = <-done
-		if err != nil {
-			barClearOnError(bar)
-			return results, err
-		}
-		err = bar.Add(1)
-		if err != nil {
-			barClearOnError(bar)
-			return nil, err
+	streamed, err := streamCorpus(ctx, appID, corpusPath, ac, parallelism)
+	results := make([]AudioCorpusItem, 0, len(ac))
+	for i, res := range streamed {
+		if res == nil {
+			break
 		}
+		aci := ac[i]
+		aci.Hypothesis = res.transcript()
+		results = append(results, aci)
 	}
-	err = bar.Close()
-	if err != nil {
-		return results, err
-	}
-
-	return results, nil
+	return results, err
 }
+
 func barClearOnError(_ *progressbar.ProgressBar) {
 	_, _ = fmt.Fprint(os.Stderr, "\n\n")
 }
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranscriptLines(t *testing.T) {
+	lines := []string{"*turn_on turn on the [lights](device)", "a [stray bracket", "(noise) hello"}
+	tests := []struct {
+		skipInvalid bool
+		kept        []string
+		transcripts []string
+	}{
+		{false, lines, []string{"turn on the lights", "a [stray bracket", "hello"}},
+		{true, []string{lines[0], lines[2]}, []string{"turn on the lights", "hello"}},
+	}
+	for _, test := range tests {
+		kept, transcripts := transcriptLines(lines, test.skipInvalid)
+		if !reflect.DeepEqual(test.kept, kept) || !reflect.DeepEqual(test.transcripts, transcripts) {
+			t.Errorf("With skipInvalid %v: expected %q and %q, got %q and %q", test.skipInvalid, test.kept, test.transcripts, kept, transcripts)
+		}
+	}
+}
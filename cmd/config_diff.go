@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/speechly/cli/pkg/upload"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around the changes of a unified diff.
+	diffContext = 3
+	// maxDiffEdits limits the work of diffLines. Files that differ by more lines are shown as fully replaced.
+	maxDiffEdits = 2000
+)
+
+type lineOp int
+
+const (
+	lineEqual lineOp = iota
+	lineDelete
+	lineInsert
+)
+
+type lineEdit struct {
+	op   lineOp
+	line string
+}
+
+// printConfigDiff prints a unified diff from the configuration files in the local directory to the deployed
+// files. The local files are the ones deploy would upload, and any other local files with the names of
+// deployed files. Returns whether any files differ.
+func printConfigDiff(out io.Writer, localDir string, deployed []upload.TarFile) (bool, error) {
+	localFiles, err := upload.ListFiles(localDir)
+	if err != nil {
+		return false, err
+	}
+	local := make(map[string][]byte)
+	for _, f := range localFiles {
+		if local[f.Name], err = os.ReadFile(f.Path); err != nil {
+			return false, err
+		}
+	}
+	remote := make(map[string][]byte)
+	for _, f := range deployed {
+		remote[f.Name] = f.Contents
+		if _, ok := local[f.Name]; ok {
+			continue
+		}
+		contents, err := os.ReadFile(filepath.Join(localDir, filepath.FromSlash(f.Name)))
+		if err == nil {
+			local[f.Name] = contents
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+
+	changed := false
+	for _, name := range sortedKeys(unionKeys(local, remote)) {
+		a, inLocal := local[name]
+		b, inRemote := remote[name]
+		if inLocal && inRemote && bytes.Equal(a, b) {
+			continue
+		}
+		changed = true
+		nameA, nameB := "local/"+name, "deployed/"+name
+		if !inLocal {
+			nameA = "/dev/null"
+		}
+		if !inRemote {
+			nameB = "/dev/null"
+		}
+		writeUnifiedDiff(out, nameA, nameB, diffLines(splitLines(a), splitLines(b)))
+	}
+	return changed, nil
+}
+
+// splitLines splits the contents to lines that keep their line endings.
+func splitLines(contents []byte) []string {
+	lines := strings.SplitAfter(string(contents), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script from a to b.
+func diffLines(a []string, b []string) []lineEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var edits []lineEdit
+	for _, line := range a[:prefix] {
+		edits = append(edits, lineEdit{lineEqual, line})
+	}
+	edits = append(edits, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, lineEdit{lineEqual, line})
+	}
+	return edits
+}
+
+// myersDiff implements the O(ND) difference algorithm of Myers. If the lines differ by more than maxDiffEdits
+// lines, all lines of a are deleted and all lines of b inserted instead.
+func myersDiff(a []string, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace holds v[-d..d] before each step d, for backtracking the path
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace)
+			}
+		}
+	}
+	return replaceLines(a, b)
+}
+
+func backtrackDiff(a []string, b []string, trace [][]int) []lineEdit {
+	var edits []lineEdit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{lineEqual, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			edits = append(edits, lineEdit{lineInsert, b[y-1]})
+		} else {
+			edits = append(edits, lineEdit{lineDelete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, lineEdit{lineEqual, a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceLines(a []string, b []string) []lineEdit {
+	edits := make([]lineEdit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, lineEdit{lineDelete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, lineEdit{lineInsert, line})
+	}
+	return edits
+}
+
+// writeUnifiedDiff writes the edits in the unified diff format, with diffContext lines of context. Nothing
+// is written if there are no changes.
+func writeUnifiedDiff(out io.Writer, nameA string, nameB string, edits []lineEdit) {
+	// lineA and lineB are the numbers of the lines of a and b before each edit
+	lineA, lineB := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		lineA[i+1], lineB[i+1] = lineA[i], lineB[i]
+		if e.op != lineInsert {
+			lineA[i+1]++
+		}
+		if e.op != lineDelete {
+			lineB[i+1]++
+		}
+	}
+
+	header := false
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].op == lineEqual {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk over the changes separated by at most 2*diffContext unchanged lines
+		end := i
+		for {
+			for end < len(edits) && edits[end].op != lineEqual {
+				end++
+			}
+			next := end
+			for next < len(edits) && edits[next].op == lineEqual {
+				next++
+			}
+			if next < len(edits) && next-end <= 2*diffContext {
+				end = next
+				continue
+			}
+			end += diffContext
+			if end > next {
+				end = next
+			}
+			break
+		}
+
+		if !header {
+			fmt.Fprintf(out, "--- %s\n+++ %s\n", nameA, nameB)
+			header = true
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(lineA[start], lineA[end]-lineA[start]), hunkRange(lineB[start], lineB[end]-lineB[start]))
+		for _, e := range edits[start:end] {
+			prefix := " "
+			switch e.op {
+			case lineDelete:
+				prefix = "-"
+			case lineInsert:
+				prefix = "+"
+			}
+			fmt.Fprint(out, prefix+e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				fmt.Fprint(out, "\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+}
+
+// hunkRange formats the range of a hunk starting after line start (counting from 0) with count lines.
+func hunkRange(start int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
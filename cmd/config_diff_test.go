@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/speechly/cli/pkg/upload"
+)
+
+func TestPrintConfigDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		local    map[string]string
+		deployed []upload.TarFile
+		expected string
+	}{
+		{
+			name:     "unchanged",
+			local:    map[string]string{"config.yaml": "a\nb\n"},
+			deployed: []upload.TarFile{{Name: "config.yaml", Contents: []byte("a\nb\n")}},
+		},
+		{
+			name:     "only local",
+			local:    map[string]string{"new.yaml": "a\nb\n"},
+			expected: "--- local/new.yaml\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:     "only deployed",
+			deployed: []upload.TarFile{{Name: "entities/old.csv", Contents: []byte("a\n")}},
+			expected: "--- /dev/null\n+++ deployed/entities/old.csv\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name:     "no newline at end of file",
+			local:    map[string]string{"config.yaml": "a\nb"},
+			deployed: []upload.TarFile{{Name: "config.yaml", Contents: []byte("a\nc\n")}},
+			expected: "--- local/config.yaml\n+++ deployed/config.yaml\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n",
+		},
+		{
+			name:     "local file not uploaded",
+			local:    map[string]string{"notes.txt": "a\n"},
+			deployed: []upload.TarFile{{Name: "notes.txt", Contents: []byte("b\n")}},
+			expected: "--- local/notes.txt\n+++ deployed/notes.txt\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, contents := range test.local {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var out bytes.Buffer
+		changed, err := printConfigDiff(&out, dir, test.deployed)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if out.String() != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, out.String())
+		}
+		if changed != (test.expected != "") {
+			t.Errorf("%s: expected changed to be %v", test.name, test.expected != "")
+		}
+	}
+}
+
+func TestWriteUnifiedDiffHunks(t *testing.T) {
+	lines := func(changes map[int]string) []string {
+		var result []string
+		for i := 1; i <= 20; i++ {
+			line := fmt.Sprintf("l%d", i)
+			if c, ok := changes[i]; ok {
+				line = c
+			}
+			result = append(result, line+"\n")
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		changes  map[int]string
+		expected []string
+	}{
+		{
+			name:    "merged",
+			changes: map[int]string{2: "x", 9: "y"},
+			expected: []string{"--- a", "+++ b", "@@ -1,12 +1,12 @@",
+				" l1", "-l2", "+x", " l3", " l4", " l5", " l6", " l7", " l8", "-l9", "+y", " l10", " l11", " l12"},
+		},
+		{
+			name:    "separate",
+			changes: map[int]string{2: "x", 10: "y"},
+			expected: []string{"--- a", "+++ b", "@@ -1,5 +1,5 @@",
+				" l1", "-l2", "+x", " l3", " l4", " l5",
+				"@@ -7,7 +7,7 @@",
+				" l7", " l8", " l9", "-l10", "+y", " l11", " l12", " l13"},
+		},
+		{
+			name:    "at the end",
+			changes: map[int]string{20: "x"},
+			expected: []string{"--- a", "+++ b", "@@ -17,4 +17,4 @@",
+				" l17", " l18", " l19", "-l20", "+x"},
+		},
+		{
+			name: "unchanged",
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		writeUnifiedDiff(&out, "a", "b", diffLines(lines(nil), lines(test.changes)))
+		expected := ""
+		if len(test.expected) > 0 {
+			expected = strings.Join(test.expected, "\n") + "\n"
+		}
+		if out.String() != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, expected, out.String())
+		}
+	}
+}
+
+func TestMyersDiffLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		replaced bool
+	}{
+		{"within the limit", 10, false},
+		{"over the limit", maxDiffEdits/2 + 1, true},
+	}
+	for _, test := range tests {
+		// the common line is kept by a shortest edit script, but not when all lines are replaced
+		a, b := []string{"common\n"}, []string{}
+		for i := 0; i < test.n; i++ {
+			a = append(a, fmt.Sprintf("a%d\n", i))
+			b = append(b, fmt.Sprintf("b%d\n", i))
+		}
+		b = append(b, "common\n")
+		edits := myersDiff(a, b)
+		var equal, deleted, inserted int
+		for _, e := range edits {
+			switch e.op {
+			case lineEqual:
+				equal++
+			case lineDelete:
+				deleted++
+			case lineInsert:
+				inserted++
+			}
+		}
+		expectedEqual := 1
+		if test.replaced {
+			expectedEqual = 0
+		}
+		if equal != expectedEqual || deleted != len(a)-expectedEqual || inserted != len(b)-expectedEqual {
+			t.Errorf("%s: expected %d equal, %d deleted and %d inserted lines, got %d, %d and %d",
+				test.name, expectedEqual, len(a)-expectedEqual, len(b)-expectedEqual, equal, deleted, inserted)
+		}
+		if test.replaced && !reflect.DeepEqual(replaceLines(a, b), edits) {
+			t.Errorf("%s: expected all lines of a to be deleted before inserting the lines of b", test.name)
+		}
+	}
+}
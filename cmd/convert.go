@@ -30,10 +30,16 @@ var convertCmd = &cobra.Command{
 	Use:   "convert",
 	Short: "Converts an Alexa Interaction Model in JSON format to a Speechly configuration",
 	Example: `speechly convert my-alexa-skill.json
-speechly convert --language en-US my-alexa-skill.json`,
+speechly convert --language en-US my-alexa-skill.json
+speechly convert --on-conflict backup my-alexa-skill.json`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := cmd.Context()
+		onConflict, _ := cmd.Flags().GetString("on-conflict")
+		policy, err := upload.ParseConflictPolicy(onConflict)
+		if err != nil {
+			log.Fatal(err)
+		}
 		language, _ := cmd.Flags().GetString("language")
 		if len(language) == 0 {
 			language = "en-US"
@@ -77,7 +83,7 @@ speechly convert --language en-US my-alexa-skill.json`,
 			log.Printf("Conversion done!")
 		}
 
-		if err := upload.ExtractTarToDir(".", bytes.NewReader(convertResult.Result.DataChunk)); err != nil {
+		if err := upload.ExtractTarToDir(".", bytes.NewReader(convertResult.Result.DataChunk), policy); err != nil {
 			log.Fatalf("Error when extracting configuration: %s", err)
 		}
 	},
@@ -86,4 +92,5 @@ speechly convert --language en-US my-alexa-skill.json`,
 func init() {
 	RootCmd.AddCommand(convertCmd)
 	convertCmd.Flags().StringP("language", "l", "en-US", "Language of input")
+	convertCmd.Flags().String("on-conflict", string(upload.OverwriteOnConflict), "What to do with files in the current directory that differ from the converted ones: skip, overwrite or backup. With backup, the existing file is renamed with a .bak suffix.")
 }
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	analyticsv1 "github.com/speechly/api/go/speechly/analytics/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/speechly/cli/pkg/clients"
+	"github.com/speechly/cli/pkg/sal"
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage <app_id> <config_dir>",
+	Short: "Compare recent user utterances against the examples generated from a configuration",
+	Long:  "Fetches a sample of recent utterances of the application and a large sample of examples compiled from the configuration in the given directory, and reports the intents, entity values and words that users say but the configuration never generates.\n\nThe intents and entities of the user utterances are taken from their SAL annotations. Coverage is the share of the intents, entity values and words in the user utterances that also occur in the generated examples.",
+	Example: `speechly coverage <app_id> /path/to/config
+speechly coverage <app_id> /path/to/config --total 50000 --limit 50`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		appId, inputDir := args[0], args[1]
+		total, _ := cmd.Flags().GetInt("total")
+		seed, _ := cmd.Flags().GetInt("seed")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if total < 32 {
+			log.Fatal("Total must be at least 32")
+		}
+
+		client, err := clients.AnalyticsClient(ctx)
+		if err != nil {
+			log.Fatalf("Failed to init analytics client: %s", err)
+		}
+		response, err := client.Utterances(ctx, &analyticsv1.UtterancesRequest{AppId: appId})
+		if err != nil {
+			log.Fatalf("Failed to fetch utterances data for %s: %s", appId, err)
+		}
+		var utterances []string
+		for _, utt := range response.GetUtterances() {
+			annotated := utt.GetAnnotated()
+			if annotated == "" {
+				annotated = utt.GetTranscript()
+			}
+			utterances = append(utterances, annotated)
+		}
+		if len(utterances) == 0 {
+			log.Fatalf("No recent utterances found for %s", appId)
+		}
+
+		batchSize := total
+		if batchSize > 10000 {
+			batchSize = 10000
+		}
+		compile, err := sampleCompiler(ctx, appId, inputDir, batchSize, true)
+		if err != nil {
+			log.Fatalf("Sampling failed: %s", err)
+		}
+		log.Printf("Sampling %d examples in batches of %d\n", total, batchSize)
+		var examples []string
+		messages, err := sampleTotal(compile, seed, total, false, func(batch []string) {
+			examples = append(examples, batch...)
+		})
+		if err != nil {
+			log.Fatalf("Sampling failed: %s", err)
+		}
+		if len(messages) > 0 {
+			printLineErrors(messages)
+			os.Exit(1)
+		}
+
+		c := computeCoverage(utterances, examples)
+		if err := printCoverage(cmd.OutOrStdout(), c, limit); err != nil {
+			log.Fatalf("Printing coverage failed: %s", err)
+		}
+	},
+}
+
+// coverageCounts counts the occurrences of items in the user utterances, and of those the ones that are
+// never generated from the configuration.
+type coverageCounts struct {
+	total     int
+	covered   int
+	uncovered map[string]int
+}
+
+func (c *coverageCounts) add(item string, covered bool) {
+	c.total++
+	if covered {
+		c.covered++
+		return
+	}
+	if c.uncovered == nil {
+		c.uncovered = make(map[string]int)
+	}
+	c.uncovered[item]++
+}
+
+func (c coverageCounts) rate() float64 {
+	if c.total == 0 {
+		return 1
+	}
+	return float64(c.covered) / float64(c.total)
+}
+
+type coverage struct {
+	utterances int
+	examples   int
+	intents    coverageCounts
+	entities   coverageCounts
+	words      coverageCounts
+}
+
+// utteranceItems returns the intents, entity values as type=value and words of the annotated utterance.
+// Utterances that are not valid SAL are treated as plain text.
+func utteranceItems(line string) ([]string, []string, []string) {
+	u, err := sal.Parse(line)
+	if err != nil {
+		return nil, nil, strings.Fields(strings.ToLower(line))
+	}
+	var entities []string
+	for _, ent := range u.Entities() {
+		entities = append(entities, ent.Type+"="+strings.Join(strings.Fields(strings.ToLower(ent.Text)), " "))
+	}
+	return u.Intents(), entities, strings.Fields(strings.ToLower(u.Text()))
+}
+
+func computeCoverage(utterances []string, examples []string) coverage {
+	intents, entities, words := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	for _, example := range examples {
+		i, e, w := utteranceItems(example)
+		for _, item := range i {
+			intents[item] = true
+		}
+		for _, item := range e {
+			entities[item] = true
+		}
+		for _, item := range w {
+			words[item] = true
+		}
+	}
+
+	c := coverage{utterances: len(utterances), examples: len(examples)}
+	for _, utt := range utterances {
+		i, e, w := utteranceItems(utt)
+		for _, item := range i {
+			c.intents.add(item, intents[item])
+		}
+		for _, item := range e {
+			c.entities.add(item, entities[item])
+		}
+		for _, item := range w {
+			c.words.add(item, words[item])
+		}
+	}
+	return c
+}
+
+func printCoverage(out io.Writer, c coverage, limit int) error {
+	fmt.Fprintf(out, "Utterances: %d\n", c.utterances)
+	fmt.Fprintf(out, "Generated examples: %d\n\n", c.examples)
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "COVERAGE\tRATE\tCOVERED\tTOTAL\n")
+	for _, row := range []struct {
+		name   string
+		counts coverageCounts
+	}{{"intents", c.intents}, {"entity values", c.entities}, {"words", c.words}} {
+		fmt.Fprintf(w, "%s\t%.4f\t%d\t%d\n", row.name, row.counts.rate(), row.counts.covered, row.counts.total)
+	}
+	for _, section := range []struct {
+		title  string
+		counts coverageCounts
+	}{{"UNCOVERED INTENT", c.intents}, {"UNCOVERED ENTITY VALUE", c.entities}, {"UNCOVERED WORD", c.words}} {
+		rows := make([]ResultRow, 0, len(section.counts.uncovered))
+		for item, n := range section.counts.uncovered {
+			rows = append(rows, ResultRow{Name: item, Count: n})
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		sortByCount(rows)
+		if limit > 0 && len(rows) > limit {
+			rows = rows[:limit]
+		}
+		fmt.Fprintf(w, "\n%s\tCOUNT\n", section.title)
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\t%d\n", row.Name, row.Count)
+		}
+	}
+	return w.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(coverageCmd)
+	coverageCmd.Flags().Int("total", 10000, "Number of examples to sample from the configuration.")
+	coverageCmd.Flags().Int("seed", 0, "Random seed to use when initializing the sampler.")
+	coverageCmd.Flags().Int("limit", 20, "Number of uncovered intents, entity values and words to list. 0 lists all.")
+}
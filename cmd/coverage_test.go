@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUtteranceItems(t *testing.T) {
+	tests := []struct {
+		line     string
+		intents  []string
+		entities []string
+		words    []string
+	}{
+		{
+			line:     "*turn_on Turn on the [Kitchen  Lights](device) *turn_off and off the [tv|TV](device)",
+			intents:  []string{"turn_on", "turn_off"},
+			entities: []string{"device=kitchen lights", "device=tv"},
+			words:    []string{"turn", "on", "the", "kitchen", "lights", "and", "off", "the", "tv"},
+		},
+		{
+			line:  "Hello there",
+			words: []string{"hello", "there"},
+		},
+		{
+			line:  "*turn_on [Lights(device)",
+			words: []string{"*turn_on", "[lights(device)"},
+		},
+		{
+			line:  "",
+			words: []string{},
+		},
+	}
+	for _, test := range tests {
+		intents, entities, words := utteranceItems(test.line)
+		if !reflect.DeepEqual(test.intents, intents) || !reflect.DeepEqual(test.entities, entities) || !reflect.DeepEqual(test.words, words) {
+			t.Errorf("%q: expected %v, %v and %v, got %v, %v and %v", test.line, test.intents, test.entities, test.words, intents, entities, words)
+		}
+	}
+}
+
+func TestComputeCoverage(t *testing.T) {
+	tests := []struct {
+		name       string
+		utterances []string
+		examples   []string
+		expected   coverage
+	}{
+		{
+			name:       "annotated",
+			utterances: []string{"*turn_on turn on the [Lights](device)", "*turn_on turn on the [radio](device)", "*dim dim the [lights](device)"},
+			examples:   []string{"*turn_on turn on the [lights](device)", "*turn_off turn off the [tv](device)"},
+			expected: coverage{
+				utterances: 3,
+				examples:   2,
+				intents:    coverageCounts{total: 3, covered: 2, uncovered: map[string]int{"dim": 1}},
+				entities:   coverageCounts{total: 3, covered: 2, uncovered: map[string]int{"device=radio": 1}},
+				words:      coverageCounts{total: 11, covered: 9, uncovered: map[string]int{"radio": 1, "dim": 1}},
+			},
+		},
+		{
+			name:       "plain transcripts",
+			utterances: []string{"turn on the lights please", "*turn_on [lights(device)"},
+			examples:   []string{"*turn_on turn on the [lights](device)"},
+			expected: coverage{
+				utterances: 2,
+				examples:   1,
+				words:      coverageCounts{total: 7, covered: 4, uncovered: map[string]int{"please": 1, "*turn_on": 1, "[lights(device)": 1}},
+			},
+		},
+		{
+			name:     "no utterances",
+			examples: []string{"*turn_on turn on the [lights](device)"},
+			expected: coverage{examples: 1},
+		},
+	}
+	for _, test := range tests {
+		if c := computeCoverage(test.utterances, test.examples); !reflect.DeepEqual(test.expected, c) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, c)
+		}
+	}
+}
+
+func TestCoverageCountsRate(t *testing.T) {
+	tests := []struct {
+		counts   coverageCounts
+		expected float64
+	}{
+		{coverageCounts{total: 4, covered: 3}, 0.75},
+		{coverageCounts{total: 2}, 0},
+		{coverageCounts{}, 1},
+	}
+	for _, test := range tests {
+		if rate := test.counts.rate(); rate != test.expected {
+			t.Errorf("%+v: expected %f, got %f", test.counts, test.expected, rate)
+		}
+	}
+}
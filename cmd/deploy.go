@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -29,10 +29,11 @@ func (u DeployWriter) Write(data []byte) (n int, err error) {
 var deployCmd = &cobra.Command{
 	Use:   "deploy",
 	Short: "Send the contents of a local directory to training",
-	Long:  "The contents of the directory given as argument is sent to the API and validated. Then, a new model is trained and automatically deployed as the active model for the application.",
+	Long:  "The contents of the directory given as argument is sent to the API and validated. Then, a new model is trained and automatically deployed as the active model for the application. The deploy is aborted if the files change between the validation and the upload.",
 	Example: `speechly deploy <app_id> /path/to/config
 speechly deploy --app <app_id> .
-speechly deploy --watch --app <app_id> .`,
+speechly deploy --watch --app <app_id> .
+speechly deploy --dry-run <app_id> /path/to/config`,
 	Args: cobra.RangeArgs(1, 2),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		appId, _ := cmd.Flags().GetString("app")
@@ -53,16 +54,25 @@ speechly deploy --watch --app <app_id> .`,
 		}
 		absPath, _ := filepath.Abs(inputDirectory)
 		log.Printf("Project dir: %s\n", absPath)
-		// create a tar package from files in memory
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if err := printUploadFiles(cmd.OutOrStdout(), inputDirectory); err != nil {
+				log.Fatalf("Listing files failed: %s", err)
+			}
+			return
+		}
+		// list the files to package
 		uploadData := upload.CreateTarFromDir(inputDirectory)
 
 		if len(uploadData.Files) == 0 {
 			log.Fatalf("Nothing to deploy!\n\nPlease ensure the files are named *.yaml or *.csv")
 		}
 
+		// the checksum of the validated package, compared to the deployed one
+		validated := ""
 		skipValidation, _ := cmd.Flags().GetBool("skip-validation")
 		if !skipValidation {
-			messages, err := validateUploadData(ctx, appId, uploadData)
+			messages, checksum, err := validateUploadData(ctx, appId, uploadData)
+			validated = checksum
 			if err != nil {
 				log.Fatalf("Validate failed: %s", err)
 			} else if len(messages) > 0 {
@@ -75,26 +85,24 @@ speechly deploy --watch --app <app_id> .`,
 			log.Fatalf("Error connecting to API: %s", err)
 		}
 
-		// open a stream for upload
-		stream, err := configClient.UploadTrainingData(ctx)
+		// open a stream for upload, cancelled instead of closed if the files changed after validation
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := configClient.UploadTrainingData(streamCtx)
 		if err != nil {
 			log.Fatalf("Failed to open deploy stream: %s", err)
 		}
 
-		// flush the tar from memory to the stream
+		// stream the tar package of the files to the API
 		deployWriter := DeployWriter{appId, stream}
 
-		var n int64 = 0
-		for {
-			chunk := uploadData.Buf.Next(1000000)
-			if len(chunk) == 0 {
-				break
-			}
-			nChunk, err := bytes.NewBuffer(chunk).WriteTo(deployWriter)
-			n += nChunk
-			if err != nil {
-				log.Fatalf("Streaming file data failed: %s", err)
-			}
+		n, checksum, err := streamUploadData(uploadData, deployWriter)
+		if err != nil {
+			log.Fatalf("Streaming file data failed: %s", err)
+		}
+		if validated != "" && checksum != validated {
+			cancel()
+			log.Fatalf("Deploy aborted: the files changed after validation, SHA-256 %s was validated but %s uploaded", validated, checksum)
 		}
 
 		// Response from deploy is empty, ignore:
@@ -117,5 +125,6 @@ func init() {
 	RootCmd.AddCommand(deployCmd)
 	deployCmd.Flags().StringP("app", "a", "", "Application to deploy the files to. Can be given as the first positional argument.")
 	deployCmd.Flags().BoolP("watch", "w", false, "Wait for training to be finished.")
+	deployCmd.Flags().Bool("dry-run", false, dryRunUsage)
 	deployCmd.Flags().Bool("skip-validation", false, "Skip the validation step. If there are validation issues, they will not be shown, the deploy will fail silently.")
 }
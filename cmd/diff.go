@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// diffRenderer prints a word alignment of a ground truth and a prediction as two lines with the aligned
+// words in the same columns. With color, substituted words are shown in yellow, deleted words in red and
+// inserted words in green. Without color, the gaps are filled with '*' and the errors are marked with S,
+// D and I on a third line.
+type diffRenderer struct {
+	color bool
+}
+
+// newDiffRenderer returns a renderer that uses color when stdout is a terminal and NO_COLOR is not set.
+func newDiffRenderer() diffRenderer {
+	return diffRenderer{color: isatty.IsTerminal(os.Stdout.Fd()) && os.Getenv("NO_COLOR") == ""}
+}
+
+func (d diffRenderer) print(out io.Writer, alignment []alignedWord) {
+	var gt, pred, marks strings.Builder
+	for i, a := range alignment {
+		if i > 0 {
+			gt.WriteByte(' ')
+			pred.WriteByte(' ')
+			marks.WriteByte(' ')
+		}
+		width := utf8.RuneCountInString(a.ref)
+		if w := utf8.RuneCountInString(a.hyp); w > width {
+			width = w
+		}
+		switch a.op {
+		case opMatch:
+			d.cell(&gt, a.ref, width, "")
+			d.cell(&pred, a.hyp, width, "")
+			d.cell(&marks, "", width, "")
+		case opSubstitute:
+			d.cell(&gt, a.ref, width, ansiYellow)
+			d.cell(&pred, a.hyp, width, ansiYellow)
+			d.cell(&marks, "S", width, "")
+		case opDelete:
+			d.cell(&gt, a.ref, width, ansiRed)
+			d.gap(&pred, width)
+			d.cell(&marks, "D", width, "")
+		case opInsert:
+			d.gap(&gt, width)
+			d.cell(&pred, a.hyp, width, ansiGreen)
+			d.cell(&marks, "I", width, "")
+		}
+	}
+	fmt.Fprintf(out, "└─ Ground truth: %s\n", strings.TrimRight(gt.String(), " "))
+	fmt.Fprintf(out, "└─ Prediction:   %s\n", strings.TrimRight(pred.String(), " "))
+	if !d.color {
+		fmt.Fprintf(out, "└─ Errors:       %s\n", strings.TrimRight(marks.String(), " "))
+	}
+}
+
+// cell writes the word padded to width, in the given color if colors are enabled.
+func (d diffRenderer) cell(b *strings.Builder, word string, width int, color string) {
+	if d.color && color != "" {
+		b.WriteString(color + word + ansiReset)
+	} else {
+		b.WriteString(word)
+	}
+	b.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(word)))
+}
+
+func (d diffRenderer) gap(b *strings.Builder, width int) {
+	if d.color {
+		b.WriteString(strings.Repeat(" ", width))
+	} else {
+		b.WriteString(strings.Repeat("*", width))
+	}
+}
+
+// alignExact aligns the words like alignWords, but counts words that differ only in case as
+// substitutions.
+func alignExact(ref []string, hyp []string) []alignedWord {
+	alignment := alignWords(ref, hyp)
+	for i, a := range alignment {
+		if a.op == opMatch && a.ref != a.hyp {
+			alignment[i].op = opSubstitute
+		}
+	}
+	return alignment
+}
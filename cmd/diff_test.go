@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffRendererPrint(t *testing.T) {
+	tests := []struct {
+		name      string
+		alignment []alignedWord
+		expected  string
+	}{
+		{
+			name: "all errors",
+			alignment: []alignedWord{
+				{opMatch, "turn", "turn"}, {opSubstitute, "on", "of"}, {opMatch, "the", "the"},
+				{opDelete, "kitchen", ""}, {opMatch, "lights", "lights"}, {opInsert, "", "please"},
+			},
+			expected: "" +
+				"└─ Ground truth: turn on the kitchen lights ******\n" +
+				"└─ Prediction:   turn of the ******* lights please\n" +
+				"└─ Errors:            S      D              I\n",
+		},
+		{
+			name:      "words of different widths",
+			alignment: []alignedWord{{opMatch, "to", "to"}, {opSubstitute, "münchen", "munich"}, {opInsert, "", "germany"}, {opDelete, "ok", ""}},
+			expected: "" +
+				"└─ Ground truth: to münchen ******* ok\n" +
+				"└─ Prediction:   to munich  germany **\n" +
+				"└─ Errors:          S       I       D\n",
+		},
+		{
+			name:      "case-insensitive match",
+			alignment: []alignedWord{{opMatch, "Hello", "hello"}, {opSubstitute, "there", "hear"}},
+			expected: "" +
+				"└─ Ground truth: Hello there\n" +
+				"└─ Prediction:   hello hear\n" +
+				"└─ Errors:             S\n",
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		diffRenderer{}.print(&out, test.alignment)
+		if out.String() != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, out.String())
+		}
+	}
+}
+
+func TestAlignExact(t *testing.T) {
+	tests := []struct {
+		ref      string
+		hyp      string
+		expected []alignedWord
+	}{
+		{"*turn_on Turn", "*turn_on turn", []alignedWord{{opMatch, "*turn_on", "*turn_on"}, {opSubstitute, "Turn", "turn"}}},
+		{"a b", "a", []alignedWord{{opMatch, "a", "a"}, {opDelete, "b", ""}}},
+		{"[tv](device)", "[TV](device) now", []alignedWord{{opSubstitute, "[tv](device)", "[TV](device)"}, {opInsert, "", "now"}}},
+	}
+	for _, test := range tests {
+		if result := alignExact(strings.Fields(test.ref), strings.Fields(test.hyp)); !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%q and %q: expected %v, got %v", test.ref, test.hyp, test.expected, result)
+		}
+	}
+}
@@ -21,9 +21,11 @@ import (
 var downloadCmd = &cobra.Command{
 	Use:   "download",
 	Short: "Download the active configuration or model bundle of the given app",
-	Long:  "Fetches the currently stored configuration or model bundle. This command does not check for validity of the stored configuration, but downloads the latest version.",
+	Long:  "Fetches the currently stored configuration or model bundle. This command does not check for validity of the stored configuration, but downloads the latest version.\n\nLocal files that differ from the downloaded configuration are handled according to --on-conflict. With --diff, nothing is written, but the differences between the local configuration files and the stored configuration are printed as a unified diff.",
 	Example: `speechly download <app_id> /path/to/config
-speechly download --app <app_id> . --model tflite`,
+speechly download --app <app_id> . --model tflite
+speechly download <app_id> /path/to/config --diff
+speechly download <app_id> /path/to/config --on-conflict backup`,
 	Args: cobra.RangeArgs(1, 2),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		appId, _ := cmd.Flags().GetString("app")
@@ -37,6 +39,13 @@ speechly download --app <app_id> . --model tflite`,
 		if !map[string]bool{"ort": true, "coreml": true, "tflite": true, "": true, "all": true}[model] {
 			return fmt.Errorf("\"%s\" is not a valid option. Available options are: ort, tflite, coreml and all", model)
 		}
+		if diff, _ := cmd.Flags().GetBool("diff"); diff && model != "" {
+			return fmt.Errorf("--diff can only be used when downloading the configuration")
+		}
+		onConflict, _ := cmd.Flags().GetString("on-conflict")
+		if _, err := upload.ParseConflictPolicy(onConflict); err != nil {
+			return err
+		}
 
 		return nil
 	},
@@ -61,7 +70,22 @@ speechly download --app <app_id> . --model tflite`,
 			_ = d.Close()
 		}()
 		if model == "" {
-			downloadCurrentConfiguration(ctx, d, absPath, appId)
+			files := downloadCurrentConfiguration(ctx, appId)
+			if diff, _ := cmd.Flags().GetBool("diff"); diff {
+				changed, err := printConfigDiff(cmd.OutOrStdout(), absPath, files)
+				if err != nil {
+					log.Fatalf("Comparing the configuration failed: %s", err)
+				}
+				if !changed {
+					log.Println("The local configuration is up to date.")
+				}
+				return
+			}
+			onConflict, _ := cmd.Flags().GetString("on-conflict")
+			policy, _ := upload.ParseConflictPolicy(onConflict)
+			if err := upload.WriteFiles(absPath, files, policy); err != nil {
+				log.Fatalf("Could not write the configuration: %s", err)
+			}
 		} else if model == "all" {
 			ok := downloadCurrentModel(ctx, absPath, appId, "ort")
 			ok = downloadCurrentModel(ctx, absPath, appId, "coreml") || ok
@@ -78,7 +102,8 @@ speechly download --app <app_id> . --model tflite`,
 	},
 }
 
-func downloadCurrentConfiguration(ctx context.Context, d *os.File, absPath string, appId string) {
+// downloadCurrentConfiguration returns the files of the stored configuration of the app.
+func downloadCurrentConfiguration(ctx context.Context, appId string) []upload.TarFile {
 	client, err := clients.ConfigClient(ctx)
 	if err != nil {
 		log.Fatalf("Error connecting to API: %s", err)
@@ -105,16 +130,13 @@ func downloadCurrentConfiguration(ctx context.Context, d *os.File, absPath strin
 	}
 
 	if ct == configv1.DownloadCurrentTrainingDataResponse_CONTENT_TYPE_TAR {
-		if err := upload.ExtractTarToDir(absPath, bytes.NewReader(buf)); err != nil {
+		files, err := upload.ReadTar(bytes.NewReader(buf))
+		if err != nil {
 			log.Fatalf("Could not extract the configuration: %s", err)
 		}
-	} else {
-		out := filepath.Join(absPath, "config.yaml")
-		log.Printf("Writing file %s (%d bytes)\n", out, len(buf))
-		if err := os.WriteFile(out, buf, 0755); err != nil {
-			log.Fatalf("Could not write configuration to %s: %s", out, err)
-		}
+		return files
 	}
+	return []upload.TarFile{{Name: "config.yaml", Mode: 0644, Contents: buf}}
 }
 
 func downloadCurrentModel(ctx context.Context, absPath string, appId string, model string) bool {
@@ -180,5 +202,7 @@ func downloadCurrentModel(ctx context.Context, absPath string, appId string, mod
 func init() {
 	RootCmd.AddCommand(downloadCmd)
 	downloadCmd.Flags().StringP("app", "a", "", "Application which configuration or model bundle to download. Can be given as the first positional argument.")
+	downloadCmd.Flags().Bool("diff", false, "Print a unified diff from the local configuration files to the stored configuration instead of downloading it.")
+	downloadCmd.Flags().String("on-conflict", string(upload.OverwriteOnConflict), "What to do with local files that differ from the downloaded ones: skip, overwrite or backup. With backup, the local file is renamed with a .bak suffix.")
 	downloadCmd.Flags().StringP("model", "m", "", "Model bundle machine learning framework. Available options are: ort, tflite, coreml and all. This feature is available on Enterprise plans (https://speechly.com/pricing)")
 }
@@ -3,9 +3,12 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
-	"github.com/speechly/nwalgo"
 	"github.com/spf13/cobra"
+
+	"github.com/speechly/cli/pkg/augment"
 )
 
 var evaluateCmd = &cobra.Command{
@@ -17,79 +20,356 @@ var evaluateCmd = &cobra.Command{
 var nluCmd = &cobra.Command{
 	Use:   "nlu",
 	Short: "Evaluate the NLU accuracy of the given application model",
-	Long:  "To run NLU evaluation, you need a set of ground truth annotations. Use the `annotate` command to get started.",
+	Long:  "To run NLU evaluation, you need a set of ground truth annotations. Use the `annotate` command to get started.\n\nWith --predictions, no API calls are made. Instead, previously annotated predictions (e.g. the output of `annotate`) are scored against the ground truth annotations line by line.\n\nAn utterance is correct if its annotation matches the ground truth exactly. Use --ignore-case, --ignore-values, --ignore-entity-boundaries, --ignore-intent and --unordered-segments to relax the matching and isolate which part of the annotations the model gets wrong. The partial credit score gives credit for each matching intent and entity of an utterance.",
 	Example: `speechly evaluate nlu <app_id> ground-truths.txt
-speechly evaluate nlu <app_id> ground-truths.txt --reference-date 2021-01-20`,
-	Args: cobra.ExactArgs(2),
+speechly evaluate nlu <app_id> ground-truths.txt --reference-date 2021-01-20
+speechly evaluate nlu <app_id> ground-truths.txt --ignore-entity-boundaries --unordered-segments
+speechly evaluate nlu <app_id> ground-truths.txt --min-accuracy 0.9 --min-intent-accuracy turn_on=0.95
+speechly evaluate nlu <app_id> ground-truths.txt --baseline report.json --max-regression 0.01
+speechly evaluate nlu --predictions predictions.txt ground-truths.txt`,
+	Args: cobra.RangeArgs(1, 2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		predictions, _ := cmd.Flags().GetString("predictions")
+		if predictions != "" && len(args) != 1 {
+			return fmt.Errorf("with --predictions, the ground truth file must be given as the sole positional argument")
+		}
+		if predictions == "" && len(args) != 2 {
+			return fmt.Errorf("app_id and ground truth file must be given as positional arguments")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := cmd.Context()
+		matching, err := readNLUMatching(cmd)
+		if err != nil {
+			log.Fatalf("Reading matching flags failed: %v", err)
+		}
+		predictionsFile, err := cmd.Flags().GetString("predictions")
+		if err != nil {
+			log.Fatalf("Reading predictions flag failed: %v", err)
+		}
+
+		if predictionsFile != "" {
+			predictions := readLines(predictionsFile)
+			annotated := readLines(args[0])
+			predicted, err := parseAnnotatedUtterances(predictions)
+			if err != nil {
+				log.Fatalf("Invalid predictions: %v", err)
+			}
+			report := evaluateAnnotatedUtterances(predictions, predicted, annotated, matching)
+			finishEvaluation(cmd, report, args[0])
+			return
+		}
+
 		appID := args[0]
 		refD, err := readReferenceDate(cmd)
 		if err != nil {
 			log.Fatalf("reading reference date flag failed: %v", err)
 		}
 
-		res, annotated, err := runThroughWLU(ctx, appID, args[1], false, refD)
+		wluOpts, err := readWLUOptions(cmd)
 		if err != nil {
-			log.Fatalf("WLU failed: %v", err)
+			log.Fatalf("Invalid WLU options: %v", err)
 		}
-		isRelaxed, err := cmd.Flags().GetBool("relax")
+
+		res, annotated, err := runThroughWLU(ctx, appID, args[1], false, true, refD, wluOpts)
 		if err != nil {
 			log.Fatalf("WLU failed: %v", err)
 		}
 
-		evaluateAnnotatedUtterances(wluResponsesToString(res.Responses), annotated, isRelaxed)
+		report := evaluateAnnotatedUtterances(wluResponsesToString(res.Responses), wluResponsesToSegments(res.Responses), annotated, matching)
+		report.AppID = appID
+		finishEvaluation(cmd, report, args[1])
 	},
 }
 
 var asrCmd = &cobra.Command{
 	Use:   "asr",
 	Short: "Evaluate the ASR accuracy of the given application model",
-	Long:  "To run ASR evaluation, you need a set of ground truth transcripts. Use the `transcribe` command to get started.",
+	Long:  "To run ASR evaluation, you need a set of ground truth transcripts. Use the `transcribe` command to get started.\n\nWith --offline, no API calls are made. Instead, a JSON Lines file with both `transcript` and `hypothesis` fields (e.g. the output of `transcribe`) is scored.\n\nAn utterance may have several acceptable ground truths: list them in a `transcripts` array, or write alternatives inline as `{ok|okay}` (an empty alternative makes the words optional). The reference with the fewest errors is used for each utterance.\n\nWith --annotated, the ground truth transcripts are SAL annotated and an entity error rate is computed over the annotated entities: an entity counts as an error unless all of its words are transcribed correctly. With --vocabulary, the entity values imported in the given configuration directory are used as keywords and scored the same way.\n\nWith --augment, the corpus is also transcribed under perturbed conditions and the WER is reported per condition. The conditions are `noise:<SNR dB>` (noise from the wav files in --noise-dir), `gain:<dB>`, `speed:<factor>`, `clip:<fraction of peak>` and `telephony` (8 kHz narrowband channel).",
 	Example: `speechly evaluate asr <app_id> ground-truths.jsonl
-speechly evaluate asr <app_id> ground-truths.jsonl --streaming`,
-	Args: cobra.ExactArgs(2),
+speechly evaluate asr <app_id> ground-truths.jsonl --streaming
+speechly evaluate asr <app_id> ground-truths.jsonl --max-wer 0.1 --report report.json
+speechly evaluate asr <app_id> annotated-ground-truths.jsonl --annotated --entity-weight 5 --max-entity-error-rate 0.05
+speechly evaluate asr <app_id> ground-truths.jsonl --vocabulary config-dir
+speechly evaluate asr <app_id> ground-truths.jsonl --augment noise:20,noise:5,gain:-20,speed:1.2,clip:0.2,telephony --noise-dir noises
+speechly evaluate asr --offline results.jsonl`,
+	Args: cobra.RangeArgs(1, 2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		offline, _ := cmd.Flags().GetBool("offline")
+		if offline && len(args) != 1 {
+			return fmt.Errorf("with --offline, the results file must be given as the sole positional argument")
+		}
+		augmentSpecs, _ := cmd.Flags().GetStringSlice("augment")
+		if offline && len(augmentSpecs) > 0 {
+			return fmt.Errorf("--augment cannot be used with --offline")
+		}
+		conditions, err := readConditions(augmentSpecs)
+		if err != nil {
+			return fmt.Errorf("invalid augmentation: %v", err)
+		}
+		if noiseDir, _ := cmd.Flags().GetString("noise-dir"); noiseDir == "" {
+			for _, c := range conditions {
+				if c.Kind == augment.Noise {
+					return fmt.Errorf("%s requires --noise-dir", c)
+				}
+			}
+		}
+		if !offline && len(args) != 2 {
+			return fmt.Errorf("app_id and ground truth file must be given as positional arguments")
+		}
+		annotated, _ := cmd.Flags().GetBool("annotated")
+		vocabulary, _ := cmd.Flags().GetString("vocabulary")
+		if cmd.Flags().Changed("max-entity-error-rate") && !annotated && vocabulary == "" {
+			return fmt.Errorf("--max-entity-error-rate requires --annotated or --vocabulary")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := cmd.Context()
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			log.Fatalf("Reading offline flag failed: %v", err)
+		}
+		scoring, err := readASRScoring(cmd)
+		if err != nil {
+			log.Fatalf("Invalid entity scoring options: %v", err)
+		}
+
+		if offline {
+			ac, err := readAudioCorpus(args[0])
+			if err != nil {
+				log.Fatalf("Reading results failed: %v", err)
+			}
+			for _, aci := range ac {
+				if !aci.hasGroundTruth() {
+					log.Fatalf("Missing ground truth for %s", aci.Audio)
+				}
+				if strings.TrimSpace(aci.Hypothesis) == "" {
+					log.Fatalf("Missing hypothesis for %s", aci.Audio)
+				}
+			}
+			finishEvaluation(cmd, evaluateTranscripts(ac, scoring), args[0])
+			return
+		}
+
 		appID := args[0]
 		var ac []AudioCorpusItem
 		useStreaming, err := cmd.Flags().GetBool("streaming")
 		if err != nil {
 			log.Fatalf("Reading streaming flag failed: %v", err)
 		}
-		if useStreaming {
-			ac, err = transcribeWithStreamingAPI(ctx, appID, args[1], true)
-		} else {
-			ac, err = transcribeWithBatchAPI(ctx, appID, args[1], true)
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			log.Fatalf("Reading parallel flag failed: %v", err)
+		}
+		transcribe := func(corpusPath string) ([]AudioCorpusItem, error) {
+			if useStreaming {
+				return transcribeWithStreamingAPI(ctx, appID, corpusPath, true, parallel)
+			}
+			return transcribeWithBatchAPI(ctx, appID, corpusPath, true)
 		}
+		augmentSpecs, err := cmd.Flags().GetStringSlice("augment")
+		if err != nil {
+			log.Fatalf("Reading augment flag failed: %v", err)
+		}
+		conditions, err := readConditions(augmentSpecs)
+		if err != nil {
+			log.Fatalf("Invalid augmentation: %v", err)
+		}
+		noiseDir, _ := cmd.Flags().GetString("noise-dir")
+		seed, _ := cmd.Flags().GetInt64("augment-seed")
+		augmenter, err := newAugmenter(noiseDir, seed)
+		if err != nil {
+			log.Fatalf("Reading noise failed: %v", err)
+		}
+
+		ac, err = transcribe(args[1])
 		if err != nil {
 			log.Fatalf("Transcription failed: %v", err)
 		}
 
-		ed := EditDistance{}
-		for _, aci := range ac {
-			wd, err := wordDistance(aci.Transcript, aci.Hypothesis)
+		report := evaluateTranscripts(ac, scoring)
+		report.AppID = appID
+		if len(conditions) > 0 {
+			report.Conditions, err = evaluateConditions(args[1], conditions, augmenter, scoring, transcribe)
 			if err != nil {
-				log.Fatalf("Error in result generation: %v", err)
+				log.Fatalf("Augmented evaluation failed: %v", err)
 			}
-			if wd.dist > 0 && wd.base > 0 {
-				aln1, aln2, _ := nwalgo.Align(aci.Transcript, aci.Hypothesis, "*", 1, -1, -1)
-				fmt.Printf("\nAudio: %s\n", aci.Audio)
-				fmt.Printf("└─ Ground truth: %s\n", aln1)
-				fmt.Printf("└─ Prediction:   %s\n", aln2)
+			if err := printConditions(os.Stdout, report, conditions); err != nil {
+				log.Fatalf("Printing results failed: %v", err)
 			}
-			ed = ed.Add(wd)
 		}
-		fmt.Printf("\nWord Error Rate (WER): %.2f (%.0d/%.0d)\n", ed.AsER(), ed.dist, ed.base)
+		finishEvaluation(cmd, report, args[1])
 	},
 }
 
+// asrScoring holds the options of entity-aware ASR scoring.
+type asrScoring struct {
+	annotated    bool
+	vocabulary   *vocabulary
+	entityWeight float64
+}
+
+func (s asrScoring) enabled() bool {
+	return s.annotated || s.vocabulary != nil
+}
+
+func readASRScoring(cmd *cobra.Command) (asrScoring, error) {
+	var s asrScoring
+	var err error
+	if s.annotated, err = cmd.Flags().GetBool("annotated"); err != nil {
+		return s, err
+	}
+	if s.entityWeight, err = cmd.Flags().GetFloat64("entity-weight"); err != nil {
+		return s, err
+	}
+	if s.entityWeight <= 0 {
+		return s, fmt.Errorf("entity weight must be positive")
+	}
+	configDir, err := cmd.Flags().GetString("vocabulary")
+	if err != nil {
+		return s, err
+	}
+	if configDir != "" {
+		if s.vocabulary, err = readVocabulary(configDir); err != nil {
+			return s, fmt.Errorf("reading vocabulary failed: %v", err)
+		}
+	}
+	return s, nil
+}
+
+func evaluateTranscripts(ac []AudioCorpusItem, scoring asrScoring) EvaluationReport {
+	ed := EditDistance{}
+	entities := make(map[string]Score)
+	km := &KeywordMetrics{}
+	var weightedDist, weightedBase float64
+	diff := newDiffRenderer()
+	for _, aci := range ac {
+		best, err := bestReference(aci, scoring)
+		if err != nil {
+			log.Fatalf("Invalid ground truth of %s: %v", aci.Audio, err)
+		}
+		ref, spans, wd := best.words, best.spans, best.distance
+		hyp := strings.Fields(aci.Hypothesis)
+		es := scoreEntities(ref, hyp, spans, scoring.entityWeight)
+		if wd.dist > 0 && wd.base > 0 {
+			fmt.Printf("\nAudio: %s\n", aci.Audio)
+			diff.print(os.Stdout, alignWords(ref, hyp))
+			if len(es.missed) > 0 {
+				missed := make([]string, len(es.missed))
+				for i, m := range es.missed {
+					missed[i] = spanText(ref, m)
+				}
+				fmt.Printf("└─ Missed:       %s\n", strings.Join(missed, ", "))
+			}
+		}
+		ed = ed.Add(wd)
+
+		for _, s := range spans {
+			hit := true
+			for _, m := range es.missed {
+				if m == s {
+					hit = false
+				}
+			}
+			entities[s.entityType] = entities[s.entityType].add(hit)
+		}
+		km.EntityTotal += len(spans)
+		km.EntityErrors += len(es.missed)
+		weightedDist += es.weightedDist
+		weightedBase += es.weightedBase
+	}
+	fmt.Printf("\nWord Error Rate (WER): %.2f (%.0d/%.0d)\n", ed.AsER(), ed.dist, ed.base)
+	report := EvaluationReport{
+		Type:   "asr",
+		Total:  len(ac),
+		WER:    ed.AsER(),
+		Errors: ed.dist,
+		Words:  ed.base,
+	}
+	if scoring.enabled() {
+		// the rates are left out instead of NaN, which the JSON report cannot hold
+		if km.EntityTotal > 0 {
+			rate := float64(km.EntityErrors) / float64(km.EntityTotal)
+			km.EntityErrorRate = &rate
+		}
+		if weightedBase > 0 {
+			weighted := weightedDist / weightedBase
+			km.WeightedWER = &weighted
+		}
+		printKeywordMetrics(os.Stdout, km, entities, scoring.entityWeight)
+		report.Entities = entities
+		report.KeywordMetrics = km
+	}
+	return report
+}
+
+// scoredReference is a ground truth reference with its word errors against the hypothesis.
+type scoredReference struct {
+	words    []string
+	spans    []entitySpan
+	distance EditDistance
+}
+
+// bestReference scores the hypothesis against every reference of the item and returns the one with the
+// fewest word errors.
+func bestReference(aci AudioCorpusItem, scoring asrScoring) (scoredReference, error) {
+	refs, err := aci.references()
+	if err != nil {
+		return scoredReference{}, err
+	}
+	var best scoredReference
+	for i, r := range refs {
+		words, spans, err := referenceWords(r, scoring.annotated, scoring.vocabulary)
+		if err != nil {
+			return best, err
+		}
+		wd, err := wordDistance(strings.Join(words, " "), aci.Hypothesis)
+		if err != nil {
+			return best, err
+		}
+		if i == 0 || wd.dist < best.distance.dist {
+			best = scoredReference{words: words, spans: spans, distance: wd}
+		}
+	}
+	return best, nil
+}
+
+// finishEvaluation stores the report in the evaluation history and checks the quality gate.
+func finishEvaluation(cmd *cobra.Command, report EvaluationReport, corpusPath string) {
+	if err := recordEvaluation(cmd, report, corpusPath); err != nil {
+		log.Printf("Storing evaluation history failed: %v", err)
+	}
+	enforceQualityGate(cmd, report)
+}
+
 func init() {
 	RootCmd.AddCommand(evaluateCmd)
 	evaluateCmd.AddCommand(nluCmd)
 	nluCmd.Flags().StringP("reference-date", "r", "", "Reference date in YYYY-MM-DD format, if not provided use current date.")
-	nluCmd.Flags().Bool("relax", false, "Ignore normalized entity values and casing in matching.")
+	addNLUMatchingFlags(nluCmd)
+	nluCmd.Flags().Float64("min-accuracy", 0, "Fail if the accuracy is below the given value.")
+	nluCmd.Flags().StringToString("min-intent-accuracy", nil, "Fail if the accuracy of utterances with the given intent is below the value, e.g. turn_on=0.9.")
+	nluCmd.Flags().StringToString("min-entity-accuracy", nil, "Fail if the accuracy of utterances with the given entity type is below the value, e.g. device=0.9.")
+	addWLUFlags(nluCmd)
+	nluCmd.Flags().String("predictions", "", "Score the annotated predictions in the given file instead of calling the API.")
+	addQualityGateFlags(nluCmd)
+	addHistoryFlags(nluCmd)
 
 	evaluateCmd.AddCommand(asrCmd)
 	asrCmd.Flags().Bool("streaming", false, "Use the Streaming API instead of the Batch API.")
+	asrCmd.Flags().Int("parallel", 4, "Number of concurrent streams when using the Streaming API.")
+	asrCmd.Flags().Float64("max-wer", 0, "Fail if the word error rate is above the given value.")
+	asrCmd.Flags().Bool("offline", false, "Score transcripts and hypotheses from the given JSON Lines file instead of calling the API.")
+	asrCmd.Flags().Bool("annotated", false, "The ground truth transcripts are SAL annotated. Compute the entity error rate over the annotated entities.")
+	asrCmd.Flags().String("vocabulary", "", "Configuration directory whose imported entity values are scored as keywords.")
+	asrCmd.Flags().Float64("entity-weight", 1, "Weight of the errors inside entities in the weighted WER.")
+	asrCmd.Flags().StringSlice("augment", nil, "Also evaluate under the given perturbations, e.g. noise:10,gain:-12,speed:1.1,clip:0.3,telephony.")
+	asrCmd.Flags().String("noise-dir", "", "Directory of 16kHz mono wav files used by the noise augmentation.")
+	asrCmd.Flags().Int64("augment-seed", 1, "Seed for choosing the noise files and offsets.")
+	asrCmd.Flags().Float64("max-entity-error-rate", 0, "Fail if the entity error rate is above the given value.")
+	asrCmd.Flags().StringToString("min-entity-recognition", nil, "Fail if the share of recognized entities of the given type is below the value, e.g. product=0.9.")
+	addQualityGateFlags(asrCmd)
+	addHistoryFlags(asrCmd)
 }
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// EvaluationReport contains the metrics of a single evaluation run. It is written with --report and
+// read back with --baseline.
+type EvaluationReport struct {
+	AppID    string           `json:"app_id"`
+	Type     string           `json:"type"`
+	Total    int              `json:"total"`
+	WER      float64          `json:"wer"`
+	Errors   int              `json:"errors"`
+	Words    int              `json:"words"`
+	Accuracy float64          `json:"accuracy"`
+	Hits     int              `json:"hits"`
+	Intents  map[string]Score `json:"intents,omitempty"`
+	Entities map[string]Score `json:"entities,omitempty"`
+	// Conditions holds the WER of each augmentation condition of an ASR evaluation.
+	Conditions map[string]ConditionScore `json:"conditions,omitempty"`
+	// NLU holds the intent and entity level metrics of an NLU evaluation.
+	NLU *NLUMetrics `json:"nlu,omitempty"`
+	*KeywordMetrics
+}
+
+// Score is the accuracy of the utterances that contain a given intent or entity type. In ASR reports, it
+// is the share of the entities of a given type that were transcribed correctly.
+type Score struct {
+	Hits     int     `json:"hits"`
+	Total    int     `json:"total"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+func (s Score) add(hit bool) Score {
+	s.Total += 1
+	if hit {
+		s.Hits += 1
+	}
+	s.Accuracy = float64(s.Hits) / float64(s.Total)
+	return s
+}
+
+// QualityGate holds the thresholds an evaluation report must satisfy. Negative values disable the check.
+type QualityGate struct {
+	MaxWER             float64
+	MaxEntityErrorRate float64
+	MinAccuracy        float64
+	MinIntentAccuracy  map[string]float64
+	MinEntityAccuracy  map[string]float64
+	Baseline           *EvaluationReport
+	MaxRegression      float64
+}
+
+// Check returns a description of each threshold the report does not satisfy.
+func (g QualityGate) Check(r EvaluationReport) []string {
+	var failures []string
+	if g.MaxWER >= 0 && r.WER > g.MaxWER {
+		failures = append(failures, fmt.Sprintf("WER %.4f is above the maximum %.4f", r.WER, g.MaxWER))
+	}
+	if g.MaxEntityErrorRate >= 0 && r.KeywordMetrics != nil {
+		if r.EntityErrorRate == nil {
+			failures = append(failures, "no entities to score for the maximum entity error rate")
+		} else if *r.EntityErrorRate > g.MaxEntityErrorRate {
+			failures = append(failures, fmt.Sprintf("entity error rate %.4f is above the maximum %.4f", *r.EntityErrorRate, g.MaxEntityErrorRate))
+		}
+	}
+	if g.MinAccuracy >= 0 && r.Accuracy < g.MinAccuracy {
+		failures = append(failures, fmt.Sprintf("accuracy %.4f is below the minimum %.4f", r.Accuracy, g.MinAccuracy))
+	}
+	failures = append(failures, checkScores("intent", r.Intents, g.MinIntentAccuracy)...)
+	failures = append(failures, checkScores("entity", r.Entities, g.MinEntityAccuracy)...)
+
+	if b := g.Baseline; b != nil {
+		if b.Type != r.Type {
+			return append(failures, fmt.Sprintf("baseline is a %s report, cannot compare to %s", b.Type, r.Type))
+		}
+		if r.Type != "nlu" && r.WER > b.WER+g.MaxRegression {
+			failures = append(failures, fmt.Sprintf("WER %.4f regressed from baseline %.4f", r.WER, b.WER))
+		}
+		if r.KeywordMetrics != nil && b.KeywordMetrics != nil && r.EntityErrorRate != nil && b.EntityErrorRate != nil &&
+			*r.EntityErrorRate > *b.EntityErrorRate+g.MaxRegression {
+			failures = append(failures, fmt.Sprintf("entity error rate %.4f regressed from baseline %.4f", *r.EntityErrorRate, *b.EntityErrorRate))
+		}
+		if r.Type != "asr" && r.Accuracy < b.Accuracy-g.MaxRegression {
+			failures = append(failures, fmt.Sprintf("accuracy %.4f regressed from baseline %.4f", r.Accuracy, b.Accuracy))
+		}
+		failures = append(failures, checkRegressions("intent", r.Intents, b.Intents, g.MaxRegression)...)
+		failures = append(failures, checkRegressions("entity", r.Entities, b.Entities, g.MaxRegression)...)
+	}
+	return failures
+}
+
+func checkScores(kind string, scores map[string]Score, thresholds map[string]float64) []string {
+	var failures []string
+	for _, name := range sortedKeys(thresholds) {
+		s, ok := scores[name]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s %s does not appear in the ground truth", kind, name))
+			continue
+		}
+		if s.Accuracy < thresholds[name] {
+			failures = append(failures, fmt.Sprintf("%s %s accuracy %.4f is below the minimum %.4f", kind, name, s.Accuracy, thresholds[name]))
+		}
+	}
+	return failures
+}
+
+func checkRegressions(kind string, scores map[string]Score, baseline map[string]Score, margin float64) []string {
+	var failures []string
+	for _, name := range sortedKeys(baseline) {
+		s, ok := scores[name]
+		if ok && s.Accuracy < baseline[name].Accuracy-margin {
+			failures = append(failures, fmt.Sprintf("%s %s accuracy %.4f regressed from baseline %.4f", kind, name, s.Accuracy, baseline[name].Accuracy))
+		}
+	}
+	return failures
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func readQualityGate(cmd *cobra.Command) (QualityGate, error) {
+	g := QualityGate{MaxWER: -1, MaxEntityErrorRate: -1, MinAccuracy: -1}
+	flags := cmd.Flags()
+	if flags.Lookup("max-wer") != nil && flags.Changed("max-wer") {
+		g.MaxWER, _ = flags.GetFloat64("max-wer")
+	}
+	if flags.Lookup("max-entity-error-rate") != nil && flags.Changed("max-entity-error-rate") {
+		g.MaxEntityErrorRate, _ = flags.GetFloat64("max-entity-error-rate")
+	}
+	if flags.Lookup("min-accuracy") != nil && flags.Changed("min-accuracy") {
+		g.MinAccuracy, _ = flags.GetFloat64("min-accuracy")
+	}
+	var err error
+	if flags.Lookup("min-intent-accuracy") != nil {
+		if g.MinIntentAccuracy, err = readThresholds(cmd, "min-intent-accuracy"); err != nil {
+			return g, err
+		}
+	}
+	for _, flag := range []string{"min-entity-accuracy", "min-entity-recognition"} {
+		if flags.Lookup(flag) != nil {
+			if g.MinEntityAccuracy, err = readThresholds(cmd, flag); err != nil {
+				return g, err
+			}
+		}
+	}
+	g.MaxRegression, _ = flags.GetFloat64("max-regression")
+	baseline, _ := flags.GetString("baseline")
+	if baseline != "" {
+		b, err := readEvaluationReport(baseline)
+		if err != nil {
+			return g, fmt.Errorf("reading baseline failed: %v", err)
+		}
+		g.Baseline = &b
+	}
+	return g, nil
+}
+
+func readThresholds(cmd *cobra.Command, flag string) (map[string]float64, error) {
+	values, err := cmd.Flags().GetStringToString(flag)
+	if err != nil {
+		return nil, err
+	}
+	thresholds := make(map[string]float64, len(values))
+	for name, v := range values {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for %s in --%s: %s", name, flag, v)
+		}
+		thresholds[name] = f
+	}
+	return thresholds, nil
+}
+
+func readEvaluationReport(fn string) (EvaluationReport, error) {
+	var r EvaluationReport
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+func writeEvaluationReport(fn string, r EvaluationReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, append(data, '\n'), 0644)
+}
+
+// enforceQualityGate writes the report if requested and exits with a non-zero status if the gate fails.
+func enforceQualityGate(cmd *cobra.Command, r EvaluationReport) {
+	reportFile, _ := cmd.Flags().GetString("report")
+	if reportFile != "" {
+		if err := writeEvaluationReport(reportFile, r); err != nil {
+			log.Fatalf("Writing report failed: %v", err)
+		}
+	}
+	gate, err := readQualityGate(cmd)
+	if err != nil {
+		log.Fatalf("Invalid quality gate: %v", err)
+	}
+	failures := gate.Check(r)
+	if len(failures) == 0 {
+		return
+	}
+	log.Println("Quality gate failed")
+	for _, f := range failures {
+		log.Printf("└─ %s", f)
+	}
+	os.Exit(1)
+}
+
+func addQualityGateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("report", "", "Write the evaluation metrics as JSON to the given file.")
+	cmd.Flags().String("baseline", "", "Evaluation report (written with --report) to compare against. Fail if the results regress.")
+	cmd.Flags().Float64("max-regression", 0, "Allowed regression from the baseline before failing.")
+}
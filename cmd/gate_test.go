@@ -0,0 +1,75 @@
+package cmd_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/speechly/cli/cmd"
+)
+
+func TestQualityGateCheck(t *testing.T) {
+	report := cmd.EvaluationReport{
+		Type:     "nlu",
+		Accuracy: 0.8,
+		Intents: map[string]cmd.Score{
+			"turn_on":  {Hits: 9, Total: 10, Accuracy: 0.9},
+			"turn_off": {Hits: 7, Total: 10, Accuracy: 0.7},
+		},
+	}
+
+	gate := cmd.QualityGate{MaxWER: -1, MinAccuracy: 0.75}
+	if failures := gate.Check(report); len(failures) != 0 {
+		t.Errorf("Expected no failures, got %v", failures)
+	}
+
+	gate.MinAccuracy = 0.85
+	gate.MinIntentAccuracy = map[string]float64{"turn_on": 0.85, "turn_off": 0.75, "unknown": 0.5}
+	if failures := gate.Check(report); len(failures) != 3 {
+		t.Errorf("Expected 3 failures, got %v", failures)
+	}
+
+	gate = cmd.QualityGate{
+		MaxWER:      -1,
+		MinAccuracy: -1,
+		Baseline: &cmd.EvaluationReport{
+			Type:     "nlu",
+			Accuracy: 0.82,
+			Intents:  map[string]cmd.Score{"turn_on": {Accuracy: 0.9}, "turn_off": {Accuracy: 0.8}},
+		},
+		MaxRegression: 0.05,
+	}
+	failures := gate.Check(report)
+	if len(failures) != 1 {
+		t.Errorf("Expected only the turn_off regression to fail, got %v", failures)
+	}
+
+	asr := cmd.EvaluationReport{Type: "asr", WER: 0.12}
+	gate = cmd.QualityGate{MaxWER: 0.1, MinAccuracy: -1}
+	if failures := gate.Check(asr); len(failures) != 1 {
+		t.Errorf("Expected WER failure, got %v", failures)
+	}
+}
+
+func TestQualityGateCheckEntityErrorRate(t *testing.T) {
+	rate := 0.2
+	tests := []struct {
+		name     string
+		metrics  *cmd.KeywordMetrics
+		expected []string
+	}{
+		{"above the maximum", &cmd.KeywordMetrics{EntityErrors: 1, EntityTotal: 5, EntityErrorRate: &rate}, []string{"entity error rate 0.2000 is above the maximum 0.1000"}},
+		{"no entities", &cmd.KeywordMetrics{}, []string{"no entities to score for the maximum entity error rate"}},
+	}
+	for _, test := range tests {
+		gate := cmd.QualityGate{MaxWER: -1, MinAccuracy: -1, MaxEntityErrorRate: 0.1}
+		if failures := gate.Check(cmd.EvaluationReport{Type: "asr", KeywordMetrics: test.metrics}); !reflect.DeepEqual(test.expected, failures) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, failures)
+		}
+	}
+
+	gate := cmd.QualityGate{MaxWER: -1, MinAccuracy: -1, MaxEntityErrorRate: -1, MaxRegression: 0.05,
+		Baseline: &cmd.EvaluationReport{Type: "asr", KeywordMetrics: &cmd.KeywordMetrics{EntityErrorRate: &rate}}}
+	if failures := gate.Check(cmd.EvaluationReport{Type: "asr", KeywordMetrics: &cmd.KeywordMetrics{}}); len(failures) != 0 {
+		t.Errorf("Expected no regression without entities, got %v", failures)
+	}
+}
@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	configv1 "github.com/speechly/api/go/speechly/config/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/speechly/cli/pkg/clients"
+)
+
+// HistoryEntry is a single evaluation run stored in the evaluation history.
+type HistoryEntry struct {
+	Time       time.Time  `json:"time"`
+	DeployedAt *time.Time `json:"deployed_at,omitempty"`
+	Corpus     string     `json:"corpus"`
+	CorpusHash string     `json:"corpus_hash"`
+	EvaluationReport
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the results of previous evaluations",
+	Long:  "Runs of `evaluate asr`, `evaluate nlu` and `evaluate slu` with --history are stored in a local history file. This command lists the stored runs and charts how the results have changed over time and across deployments.",
+	Example: `speechly evaluate history
+speechly evaluate history <app_id> --type nlu
+speechly evaluate history <app_id> --type nlu --intent turn_on --by-deployment`,
+	Args: cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+		appID := ""
+		if len(args) > 0 {
+			appID = args[0]
+		}
+		evalType, _ := cmd.Flags().GetString("type")
+		intent, _ := cmd.Flags().GetString("intent")
+		byDeployment, _ := cmd.Flags().GetBool("by-deployment")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		fn, err := historyFile(cmd)
+		if err != nil {
+			log.Fatalf("Finding evaluation history failed: %v", err)
+		}
+		entries, err := readHistory(fn)
+		if err != nil {
+			log.Fatalf("Reading evaluation history failed: %v", err)
+		}
+		entries = filterHistory(entries, appID, evalType)
+		if byDeployment {
+			entries = latestPerDeployment(entries)
+		}
+		if limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+		if len(entries) == 0 {
+			log.Fatalf("No evaluations found in %s", fn)
+		}
+
+		if err := printHistory(cmd.OutOrStdout(), entries, intent); err != nil {
+			log.Fatalf("Printing evaluation history failed: %v", err)
+		}
+	},
+}
+
+func addHistoryFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("history", false, "Store the results in the evaluation history, see `evaluate history`.")
+	cmd.Flags().String("history-file", "", "Evaluation history file. Defaults to .speechly_history.jsonl in the home directory. Implies --history.")
+}
+
+func historyFile(cmd *cobra.Command) (string, error) {
+	fn, _ := cmd.Flags().GetString("history-file")
+	if fn != "" {
+		return fn, nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".speechly_history.jsonl"), nil
+}
+
+// recordEvaluation appends the report to the evaluation history if enabled with --history or --history-file.
+func recordEvaluation(cmd *cobra.Command, r EvaluationReport, corpusPath string) error {
+	if record, _ := cmd.Flags().GetBool("history"); !record && !cmd.Flags().Changed("history-file") {
+		return nil
+	}
+	fn, err := historyFile(cmd)
+	if err != nil {
+		return err
+	}
+	entry := HistoryEntry{
+		Time:             time.Now().UTC(),
+		Corpus:           corpusPath,
+		EvaluationReport: r,
+	}
+	// readLines reads the corpus from stdin when the path is "--", so there is no file to hash
+	if corpusPath != "--" {
+		if entry.CorpusHash, err = hashFile(corpusPath); err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(corpusPath); err == nil {
+			entry.Corpus = abs
+		}
+	}
+	if r.AppID != "" {
+		entry.DeployedAt = deployedAt(cmd, r.AppID)
+	}
+	if err := appendHistory(fn, entry); err != nil {
+		return err
+	}
+	log.Printf("Stored the results in the evaluation history %s", fn)
+	return nil
+}
+
+func deployedAt(cmd *cobra.Command, appID string) *time.Time {
+	ctx := cmd.Context()
+	configClient, err := clients.ConfigClient(ctx)
+	if err != nil {
+		return nil
+	}
+	app, err := configClient.GetApp(ctx, &configv1.GetAppRequest{AppId: appID})
+	if err != nil || app.App.DeployedAtTime == nil {
+		return nil
+	}
+	t := app.App.DeployedAtTime.AsTime()
+	return &t
+}
+
+func hashFile(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func appendHistory(fn string, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readHistory(fn string) ([]HistoryEntry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var entries []HistoryEntry
+	jd := json.NewDecoder(f)
+	for {
+		var e HistoryEntry
+		err := jd.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func filterHistory(entries []HistoryEntry, appID string, evalType string) []HistoryEntry {
+	var result []HistoryEntry
+	for _, e := range entries {
+		if (appID == "" || e.AppID == appID) && (evalType == "" || e.Type == evalType) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// latestPerDeployment keeps only the last run of each deployment, corpus and evaluation type.
+func latestPerDeployment(entries []HistoryEntry) []HistoryEntry {
+	key := func(e HistoryEntry) string {
+		deployed := ""
+		if e.DeployedAt != nil {
+			deployed = e.DeployedAt.String()
+		}
+		return strings.Join([]string{e.AppID, e.Type, e.CorpusHash, deployed}, "\x00")
+	}
+	last := make(map[string]int)
+	for i, e := range entries {
+		last[key(e)] = i
+	}
+	var result []HistoryEntry
+	for i, e := range entries {
+		if last[key(e)] == i {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// metric returns the headline number of the entry: the WER for ASR and the accuracy (or the accuracy of
+// the given intent) for NLU and SLU.
+func (e HistoryEntry) metric(intent string) float64 {
+	if e.Type == "asr" {
+		return e.WER
+	}
+	if intent != "" {
+		s, ok := e.Intents[intent]
+		if !ok {
+			return math.NaN()
+		}
+		return s.Accuracy
+	}
+	return e.Accuracy
+}
+
+func printHistory(out io.Writer, entries []HistoryEntry, intent string) error {
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nTIME\tAPP\tDEPLOYED AT\tCORPUS\tTYPE\tWER\tACCURACY\tINTENT ACCURACY\n")
+	for _, e := range entries {
+		app, deployed := e.AppID, "-"
+		if app == "" {
+			app = "-"
+		}
+		if e.DeployedAt != nil {
+			deployed = e.DeployedAt.Local().Format("2006-01-02 15:04")
+		}
+		corpus := e.CorpusHash
+		if len(corpus) > 8 {
+			corpus = corpus[:8]
+		}
+		wer, acc, intentAcc := "-", "-", "-"
+		if e.Type != "nlu" {
+			wer = fmt.Sprintf("%.4f", e.WER)
+		}
+		if e.Type != "asr" {
+			acc = fmt.Sprintf("%.4f", e.Accuracy)
+			if e.NLU != nil {
+				intentAcc = fmt.Sprintf("%.4f", e.NLU.IntentAccuracy)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04"),
+			app, deployed, corpus, e.Type, wer, acc, intentAcc)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, evalType := range []string{"asr", "nlu", "slu"} {
+		var values []float64
+		for _, e := range entries {
+			if e.Type == evalType {
+				values = append(values, e.metric(intent))
+			}
+		}
+		if len(values) < 2 {
+			continue
+		}
+		title := "\nWER"
+		if evalType != "asr" {
+			title = "\n" + strings.ToUpper(evalType) + " ACCURACY"
+			if intent != "" {
+				title += " OF " + intent
+			}
+		}
+		fmt.Fprintln(out, title)
+		for _, line := range asciiChart(values, 10) {
+			fmt.Fprintln(out, line)
+		}
+	}
+	return nil
+}
+
+// asciiChart plots the values from left to right, one column per value. NaN values are left empty.
+func asciiChart(values []float64, height int) []string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		return nil
+	}
+	if hi == lo {
+		hi, lo = hi+0.01, lo-0.01
+	}
+	row := func(v float64) int {
+		return int(math.Round((v - lo) / (hi - lo) * float64(height-1)))
+	}
+
+	lines := make([]string, 0, height+1)
+	for r := height - 1; r >= 0; r-- {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%8.4f ┤", lo+(hi-lo)*float64(r)/float64(height-1))
+		for _, v := range values {
+			switch {
+			case math.IsNaN(v):
+				b.WriteString("  ")
+			case row(v) == r:
+				b.WriteString(" ●")
+			case row(v) > r:
+				b.WriteString(" │")
+			default:
+				b.WriteString("  ")
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	lines = append(lines, strings.Repeat(" ", 9)+"└"+strings.Repeat("──", len(values)))
+	return lines
+}
+
+func init() {
+	evaluateCmd.AddCommand(historyCmd)
+	historyCmd.Flags().String("history-file", "", "Evaluation history file. Defaults to .speechly_history.jsonl in the home directory.")
+	historyCmd.Flags().String("type", "", "Only show evaluations of the given type, asr, nlu or slu.")
+	historyCmd.Flags().String("intent", "", "Chart the accuracy of the given intent instead of the overall accuracy.")
+	historyCmd.Flags().Bool("by-deployment", false, "Only show the latest evaluation of each deployment and corpus.")
+	historyCmd.Flags().Int("limit", 0, "Only show the given number of most recent evaluations.")
+}
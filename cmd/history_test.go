@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsciiChart(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		height   int
+		expected []string
+	}{
+		{
+			name:   "rising",
+			values: []float64{0.1, 0.2, 0.3},
+			height: 3,
+			expected: []string{
+				"  0.3000 ┤     ●",
+				"  0.2000 ┤   ● │",
+				"  0.1000 ┤ ● │ │",
+				"         └──────",
+			},
+		},
+		{
+			name:   "constant with a missing value",
+			values: []float64{0.5, math.NaN(), 0.5},
+			height: 2,
+			expected: []string{
+				"  0.5100 ┤ ●   ●",
+				"  0.4900 ┤ │   │",
+				"         └──────",
+			},
+		},
+		{
+			name:     "only missing values",
+			values:   []float64{math.NaN()},
+			height:   2,
+			expected: nil,
+		},
+	}
+	for _, test := range tests {
+		if lines := asciiChart(test.values, test.height); !reflect.DeepEqual(test.expected, lines) {
+			t.Errorf("%s: expected\n%q\ngot\n%q", test.name, test.expected, lines)
+		}
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	entries := []HistoryEntry{
+		{EvaluationReport: EvaluationReport{AppID: "a", Type: "asr"}},
+		{EvaluationReport: EvaluationReport{AppID: "a", Type: "nlu"}},
+		{EvaluationReport: EvaluationReport{AppID: "b", Type: "nlu"}},
+	}
+	tests := []struct {
+		appID    string
+		evalType string
+		expected []int
+	}{
+		{"", "", []int{0, 1, 2}},
+		{"a", "", []int{0, 1}},
+		{"", "nlu", []int{1, 2}},
+		{"b", "asr", nil},
+	}
+	for _, test := range tests {
+		var expected []HistoryEntry
+		for _, i := range test.expected {
+			expected = append(expected, entries[i])
+		}
+		if result := filterHistory(entries, test.appID, test.evalType); !reflect.DeepEqual(expected, result) {
+			t.Errorf("App %q and type %q: expected %v, got %v", test.appID, test.evalType, expected, result)
+		}
+	}
+}
+
+func TestLatestPerDeployment(t *testing.T) {
+	first := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(deployed *time.Time, corpus string, accuracy float64) HistoryEntry {
+		return HistoryEntry{DeployedAt: deployed, CorpusHash: corpus, EvaluationReport: EvaluationReport{AppID: "a", Type: "nlu", Accuracy: accuracy}}
+	}
+	entries := []HistoryEntry{
+		entry(&first, "x", 0.1),
+		entry(&first, "y", 0.2),
+		entry(&first, "x", 0.3),
+		entry(&second, "x", 0.4),
+		entry(nil, "x", 0.5),
+		entry(nil, "x", 0.6),
+	}
+	expected := []HistoryEntry{entries[1], entries[2], entries[3], entries[5]}
+	if result := latestPerDeployment(entries); !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/speechly/cli/pkg/sal"
+	"github.com/speechly/cli/pkg/upload"
+)
+
+// KeywordMetrics measure how well the entities (or keywords) of the ground truth transcripts were
+// recognized. An entity is recognized when all of its words are transcribed correctly.
+// The rates are left out when there are no entities or words to score.
+type KeywordMetrics struct {
+	EntityErrors    int      `json:"entity_errors"`
+	EntityTotal     int      `json:"entity_total"`
+	EntityErrorRate *float64 `json:"entity_error_rate,omitempty"`
+	WeightedWER     *float64 `json:"weighted_wer,omitempty"`
+}
+
+// entitySpan is a range of reference words [start, end) belonging to an entity of the given type.
+type entitySpan struct {
+	start      int
+	end        int
+	entityType string
+}
+
+// vocabulary maps lowercased phrases to the entity type they belong to.
+type vocabulary struct {
+	phrases map[string]string
+	maxLen  int
+}
+
+type configImport struct {
+	Name   string `yaml:"name"`
+	Source string `yaml:"source"`
+	Field  int    `yaml:"field"`
+}
+
+// readVocabulary collects the entity values imported from CSV files in the configuration directory.
+func readVocabulary(dir string) (*vocabulary, error) {
+	v, err := readImports(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.phrases) == 0 {
+		return nil, fmt.Errorf("no imported entity values found in %s", dir)
+	}
+	return v, nil
+}
+
+// readImports reads the entity values imported in the configuration files that deploy would upload from the
+// directory. The vocabulary is empty if there are no imports.
+func readImports(dir string) (*vocabulary, error) {
+	files, err := upload.ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	v := &vocabulary{phrases: make(map[string]string)}
+	for _, f := range files {
+		if filepath.Ext(f.Name) != ".yaml" {
+			continue
+		}
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		var config struct {
+			Imports []configImport `yaml:"imports"`
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		for _, imp := range config.Imports {
+			if err := v.addImport(filepath.Join(dir, imp.Source), imp); err != nil {
+				return nil, fmt.Errorf("%s: import %s: %v", f.Name, imp.Name, err)
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *vocabulary) addImport(fn string, imp configImport) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	field := imp.Field
+	if field < 1 {
+		field = 1
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < field {
+			continue
+		}
+		words := strings.Fields(strings.ToLower(record[field-1]))
+		if len(words) == 0 {
+			continue
+		}
+		v.phrases[strings.Join(words, " ")] = imp.Name
+		if len(words) > v.maxLen {
+			v.maxLen = len(words)
+		}
+	}
+}
+
+// find returns the longest non-overlapping vocabulary phrases in words, scanning from left to right.
+func (v *vocabulary) find(words []string) []entitySpan {
+	lower := make([]string, len(words))
+	for i, w := range words {
+		lower[i] = strings.ToLower(w)
+	}
+	var spans []entitySpan
+	for i := 0; i < len(lower); {
+		matched := 0
+		for n := v.maxLen; n > 0; n-- {
+			if i+n > len(lower) {
+				continue
+			}
+			if t, ok := v.phrases[strings.Join(lower[i:i+n], " ")]; ok {
+				spans = append(spans, entitySpan{start: i, end: i + n, entityType: t})
+				matched = n
+				break
+			}
+		}
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+	return spans
+}
+
+// referenceWords splits the ground truth transcript into words and finds the entity spans in it. With
+// annotated, the transcript is parsed as SAL and the annotated entities are used. Otherwise the phrases of
+// the vocabulary are looked up, if one is given.
+func referenceWords(transcript string, annotated bool, vocab *vocabulary) ([]string, []entitySpan, error) {
+	if !annotated {
+		words := strings.Fields(transcript)
+		if vocab == nil {
+			return words, nil, nil
+		}
+		return words, vocab.find(words), nil
+	}
+
+	u, err := sal.Parse(transcript)
+	if err != nil {
+		return nil, nil, err
+	}
+	var words []string
+	var spans []entitySpan
+	for _, s := range u.Segments {
+		for _, n := range s.Nodes {
+			switch n.Kind {
+			case sal.WordNode:
+				words = append(words, n.Text)
+			case sal.EntityNode:
+				start := len(words)
+				words = append(words, strings.Fields(n.Text)...)
+				spans = append(spans, entitySpan{start: start, end: len(words), entityType: n.Type})
+			}
+		}
+	}
+	if vocab != nil {
+		spans = mergeSpans(spans, vocab.find(words))
+	}
+	return words, spans, nil
+}
+
+// mergeSpans adds the extra spans that do not overlap any of the given spans.
+func mergeSpans(spans []entitySpan, extra []entitySpan) []entitySpan {
+	result := spans
+	for _, e := range extra {
+		overlaps := false
+		for _, s := range spans {
+			if e.start < s.end && s.start < e.end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// entityScore is the result of scoring the entities of a single utterance.
+type entityScore struct {
+	missed       []entitySpan
+	weightedDist float64
+	weightedBase float64
+}
+
+// scoreEntities checks which entity spans of the reference were transcribed without errors. Errors inside
+// entity spans are weighted by weight in the weighted edit distance.
+func scoreEntities(ref []string, hyp []string, spans []entitySpan, weight float64) entityScore {
+	weights := make([]float64, len(ref))
+	for i := range weights {
+		weights[i] = 1
+	}
+	for _, s := range spans {
+		for i := s.start; i < s.end; i++ {
+			weights[i] = weight
+		}
+	}
+
+	// wrong[i] is set if the reference word i was not transcribed correctly or a word was inserted
+	// right before it.
+	wrong := make([]bool, len(ref))
+	insertedBefore := make([]bool, len(ref)+1)
+	var result entityScore
+	i := 0
+	for _, a := range alignWords(ref, hyp) {
+		switch a.op {
+		case opMatch:
+			i++
+		case opSubstitute, opDelete:
+			wrong[i] = true
+			result.weightedDist += weights[i]
+			i++
+		case opInsert:
+			insertedBefore[i] = true
+			if inSameSpan(spans, i-1, i) {
+				result.weightedDist += weight
+			} else {
+				result.weightedDist += 1
+			}
+		}
+	}
+	for _, w := range weights {
+		result.weightedBase += w
+	}
+
+	for _, s := range spans {
+		ok := true
+		for i := s.start; i < s.end; i++ {
+			if wrong[i] || (i > s.start && insertedBefore[i]) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			result.missed = append(result.missed, s)
+		}
+	}
+	return result
+}
+
+func inSameSpan(spans []entitySpan, a int, b int) bool {
+	for _, s := range spans {
+		if a >= s.start && b < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+func printKeywordMetrics(out io.Writer, m *KeywordMetrics, entities map[string]Score, weight float64) {
+	if m.EntityErrorRate == nil {
+		fmt.Fprint(out, "Entity Error Rate (EER): no entities to score\n")
+	} else {
+		fmt.Fprintf(out, "Entity Error Rate (EER): %.2f (%d/%d)\n", *m.EntityErrorRate, m.EntityErrors, m.EntityTotal)
+	}
+	if weight != 1 && m.WeightedWER != nil {
+		fmt.Fprintf(out, "Weighted WER (entity weight %.1f): %.2f\n", weight, *m.WeightedWER)
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nENTITY TYPE\tRECOGNIZED\tTOTAL\tACCURACY\n")
+	for _, name := range sortedKeys(entities) {
+		s := entities[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.4f\n", name, s.Hits, s.Total, s.Accuracy)
+	}
+	_ = w.Flush()
+}
+
+func spanText(words []string, s entitySpan) string {
+	return fmt.Sprintf("[%s](%s)", strings.Join(words[s.start:s.end], " "), s.entityType)
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlignWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref      string
+		hyp      string
+		expected []alignedWord
+	}{
+		{"case-insensitive match", "Turn on", "turn ON", []alignedWord{{opMatch, "Turn", "turn"}, {opMatch, "on", "ON"}}},
+		{"substitution", "the red car", "the bed car", []alignedWord{{opMatch, "the", "the"}, {opSubstitute, "red", "bed"}, {opMatch, "car", "car"}}},
+		{"deletion", "a b c", "a c", []alignedWord{{opMatch, "a", "a"}, {opDelete, "b", ""}, {opMatch, "c", "c"}}},
+		{"insertion", "a c", "a b c", []alignedWord{{opMatch, "a", "a"}, {opInsert, "", "b"}, {opMatch, "c", "c"}}},
+		{"empty hypothesis", "a b", "", []alignedWord{{opDelete, "a", ""}, {opDelete, "b", ""}}},
+		{"empty reference", "", "a", []alignedWord{{opInsert, "", "a"}}},
+		{"empty", "", "", nil},
+	}
+	for _, test := range tests {
+		if result := alignWords(strings.Fields(test.ref), strings.Fields(test.hyp)); !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestScoreEntities(t *testing.T) {
+	ref := strings.Fields("order a caffe latte")
+	product := entitySpan{start: 2, end: 4, entityType: "product"}
+	tests := []struct {
+		name     string
+		hyp      string
+		weight   float64
+		missed   []entitySpan
+		expected float64
+	}{
+		{"correct", "order a caffe latte", 2, nil, 0},
+		{"error inside entity", "order a caffe lotte", 2, []entitySpan{product}, 2},
+		{"error outside entity", "order the caffe latte", 2, nil, 1},
+		{"insertion inside entity", "order a caffe au latte", 2, []entitySpan{product}, 2},
+		{"insertion before entity", "order a hot caffe latte", 2, nil, 1},
+		{"insertion after entity", "order a caffe latte now", 2, nil, 1},
+		{"deleted entity", "order a", 2, []entitySpan{product}, 4},
+		{"unit weight", "order a caffe lotte", 1, []entitySpan{product}, 1},
+	}
+	for _, test := range tests {
+		s := scoreEntities(ref, strings.Fields(test.hyp), []entitySpan{product}, test.weight)
+		if !reflect.DeepEqual(test.missed, s.missed) {
+			t.Errorf("%s: expected missed %v, got %v", test.name, test.missed, s.missed)
+		}
+		if s.weightedDist != test.expected {
+			t.Errorf("%s: expected weighted distance %f, got %f", test.name, test.expected, s.weightedDist)
+		}
+		if base := 2 + 2*test.weight; s.weightedBase != base {
+			t.Errorf("%s: expected weighted base %f, got %f", test.name, base, s.weightedBase)
+		}
+	}
+}
+
+func TestVocabularyFind(t *testing.T) {
+	v := &vocabulary{
+		phrases: map[string]string{"caffe latte": "product", "latte": "product", "large": "size"},
+		maxLen:  2,
+	}
+	tests := []struct {
+		words    string
+		expected []entitySpan
+	}{
+		{"a Large Caffe Latte", []entitySpan{{1, 2, "size"}, {2, 4, "product"}}},
+		{"latte latte", []entitySpan{{0, 1, "product"}, {1, 2, "product"}}},
+		{"caffe", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if result := v.find(strings.Fields(test.words)); !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%q: expected %v, got %v", test.words, test.expected, result)
+		}
+	}
+}
+
+func TestReadVocabulary(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected *vocabulary
+		err      bool
+	}{
+		{
+			name: "imports",
+			files: map[string]string{
+				"config.yaml":  "templates: ''\nimports:\n  - name: product\n    source: products.csv\n    field: 2\n",
+				"sizes.yaml":   "imports:\n  - name: size\n    source: sizes.csv\n",
+				"products.csv": "1,Caffe  Latte\n2,Espresso\n3\n",
+				"sizes.csv":    "large\n\nsmall,ignored\n",
+			},
+			expected: &vocabulary{
+				phrases: map[string]string{"caffe latte": "product", "espresso": "product", "large": "size", "small": "size"},
+				maxLen:  2,
+			},
+		},
+		{
+			name: "subdirectories and ignored files",
+			files: map[string]string{
+				"config.yaml":          "templates: ''\n",
+				"entities/sizes.yaml":  "imports:\n  - name: size\n    source: entities/sizes.csv\n",
+				"entities/sizes.csv":   "large\n",
+				"drafts/products.yaml": "imports:\n  - name: product\n    source: missing.csv\n",
+				".speechlyignore":      "drafts/\n",
+			},
+			expected: &vocabulary{
+				phrases: map[string]string{"large": "size"},
+				maxLen:  1,
+			},
+		},
+		{
+			name:  "no imports",
+			files: map[string]string{"config.yaml": "templates: ''\n"},
+			err:   true,
+		},
+		{
+			name:  "missing source",
+			files: map[string]string{"config.yaml": "imports:\n  - name: product\n    source: products.csv\n"},
+			err:   true,
+		},
+		{
+			name:  "invalid configuration",
+			files: map[string]string{"config.yaml": "imports: ["},
+			err:   true,
+		},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, contents := range test.files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		v, err := readVocabulary(dir)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(test.expected, v) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, v)
+		}
+	}
+}
+
+func TestEvaluateTranscriptsWithoutEntities(t *testing.T) {
+	ac := []AudioCorpusItem{{Audio: "a.wav", Transcript: "turn on the lights", Hypothesis: "turn on the light"}}
+	report := evaluateTranscripts(ac, asrScoring{annotated: true, entityWeight: 2})
+	if report.KeywordMetrics == nil || report.EntityErrorRate != nil || report.WeightedWER == nil || *report.WeightedWER != 0.25 {
+		t.Fatalf("Expected keyword metrics without an entity error rate and with weighted WER 0.25, got %+v", report.KeywordMetrics)
+	}
+	fn := filepath.Join(t.TempDir(), "report.json")
+	if err := writeEvaluationReport(fn, report); err != nil {
+		t.Fatalf("Writing the report failed: %v", err)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "entity_error_rate") {
+		t.Errorf("Expected the entity error rate to be left out, got %s", data)
+	}
+	if _, err := readEvaluationReport(fn); err != nil {
+		t.Errorf("Reading the report back failed: %v", err)
+	}
+}
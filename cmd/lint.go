@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/speechly/cli/pkg/sal"
+)
+
+const (
+	// lintMinIntentShare is the smallest allowed size of an intent relative to the most common intent.
+	lintMinIntentShare = 0.1
+	// lintMinDiversity is the smallest allowed share of distinct word bigrams of an intent.
+	lintMinDiversity = 0.1
+	// lintDiversityBigrams is the number of word bigrams of an intent the diversity is computed over, so that
+	// it does not depend on the size of the sample. Intents with fewer bigrams are not checked.
+	lintDiversityBigrams = 200
+	// lintNearDuplicate is the word set similarity above which two different texts are near-duplicates.
+	lintNearDuplicate = 0.8
+	// lintMaxCompared limits the number of distinct texts per intent compared for near-duplicates.
+	lintMaxCompared = 1000
+	// lintMaxWords is the largest allowed number of words in an utterance.
+	lintMaxWords = 30
+	// lintMaxExamples is the number of examples shown for an issue.
+	lintMaxExamples = 3
+)
+
+// lintIssue is a problem found in the sampled examples.
+type lintIssue struct {
+	level    string
+	message  string
+	examples []string
+}
+
+// lintExample is a parsed sampled example.
+type lintExample struct {
+	annotated string
+	text      string
+	words     []string
+	// masked is the text with the entities replaced by their types, e.g. "turn on the <device>".
+	masked  string
+	intents string
+	values  []string
+}
+
+func parseLintExamples(examples []string) []lintExample {
+	var result []lintExample
+	for _, example := range examples {
+		u, err := sal.Parse(example)
+		if err != nil {
+			continue
+		}
+		e := lintExample{
+			annotated: u.String(),
+			text:      strings.ToLower(u.Text()),
+			intents:   strings.Join(u.Intents(), "+"),
+		}
+		e.words = strings.Fields(e.text)
+		var masked []string
+		for _, seg := range u.Segments {
+			for _, n := range seg.Nodes {
+				switch n.Kind {
+				case sal.WordNode:
+					masked = append(masked, strings.ToLower(n.Text))
+				case sal.EntityNode:
+					masked = append(masked, "<"+n.Type+">")
+					e.values = append(e.values, strings.Join(strings.Fields(strings.ToLower(n.Text)), " "))
+				}
+			}
+		}
+		e.masked = strings.Join(masked, " ")
+		result = append(result, e)
+	}
+	return result
+}
+
+// lintExamples analyzes the sampled examples for class imbalance, low lexical diversity, near-duplicate
+// and conflicting utterances, over-long utterances and imported entity values that are never sampled.
+func lintExamples(examples []string, vocab *vocabulary) []lintIssue {
+	parsed := parseLintExamples(examples)
+	byIntent := make(map[string][]lintExample)
+	for _, e := range parsed {
+		byIntent[e.intents] = append(byIntent[e.intents], e)
+	}
+
+	var issues []lintIssue
+	issues = append(issues, lintImbalance(byIntent)...)
+	for _, intents := range sortedKeys(byIntent) {
+		issues = append(issues, lintDiversity(intents, byIntent[intents])...)
+		issues = append(issues, lintNearDuplicates(intents, byIntent[intents])...)
+	}
+	issues = append(issues, lintConflicts(parsed)...)
+	issues = append(issues, lintLength(byIntent)...)
+	if vocab != nil {
+		issues = append(issues, lintUnusedValues(parsed, vocab)...)
+	}
+	return issues
+}
+
+func intentLabel(intents string) string {
+	if intents == "" {
+		return "without intent"
+	}
+	return intents
+}
+
+func lintImbalance(byIntent map[string][]lintExample) []lintIssue {
+	largest, max := "", 0
+	for _, intents := range sortedKeys(byIntent) {
+		if n := len(byIntent[intents]); n > max {
+			largest, max = intents, n
+		}
+	}
+	var issues []lintIssue
+	for _, intents := range sortedKeys(byIntent) {
+		n := len(byIntent[intents])
+		if share := float64(n) / float64(max); share < lintMinIntentShare {
+			issues = append(issues, lintIssue{level: "WARNING", message: fmt.Sprintf(
+				"class imbalance: intent %s has %d examples, %.1f%% of the %d examples of %s",
+				intentLabel(intents), n, 100*share, max, intentLabel(largest))})
+		}
+	}
+	return issues
+}
+
+// lintDiversity checks the share of distinct word bigrams among the first lintDiversityBigrams bigrams of
+// the intent.
+func lintDiversity(intents string, examples []lintExample) []lintIssue {
+	distinct := make(map[string]bool)
+	total := 0
+	for _, e := range examples {
+		words := append([]string{"<s>"}, e.words...)
+		for i := 1; i < len(words) && total < lintDiversityBigrams; i++ {
+			distinct[words[i-1]+" "+words[i]] = true
+			total++
+		}
+	}
+	if total < lintDiversityBigrams {
+		return nil
+	}
+	diversity := float64(len(distinct)) / float64(total)
+	if diversity >= lintMinDiversity {
+		return nil
+	}
+	return []lintIssue{{level: "WARNING", message: fmt.Sprintf(
+		"low lexical diversity: %.1f%% of the first %d word bigrams of intent %s are distinct",
+		100*diversity, total, intentLabel(intents)), examples: distinctTexts(examples, lintMaxExamples)}}
+}
+
+func distinctTexts(examples []lintExample, n int) []string {
+	var texts []string
+	seen := make(map[string]bool)
+	for _, e := range examples {
+		if len(texts) == n {
+			break
+		}
+		if !seen[e.text] {
+			seen[e.text] = true
+			texts = append(texts, e.text)
+		}
+	}
+	return texts
+}
+
+// lintNearDuplicates finds pairs of different texts of the intent with nearly the same words. The entities
+// are masked, so that texts only differing in entity values are considered the same.
+func lintNearDuplicates(intents string, examples []lintExample) []lintIssue {
+	var texts []string
+	seen := make(map[string]bool)
+	for _, e := range examples {
+		if len(texts) == lintMaxCompared {
+			break
+		}
+		if !seen[e.masked] {
+			seen[e.masked] = true
+			texts = append(texts, e.masked)
+		}
+	}
+	sets := make([]map[string]bool, len(texts))
+	for i, t := range texts {
+		sets[i] = make(map[string]bool)
+		for _, w := range strings.Fields(t) {
+			sets[i][w] = true
+		}
+	}
+	var pairs []string
+	for i := range texts {
+		for j := i + 1; j < len(texts); j++ {
+			if jaccard(sets[i], sets[j]) >= lintNearDuplicate {
+				pairs = append(pairs, texts[i]+" ~ "+texts[j])
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	if len(pairs) > lintMaxExamples {
+		pairs = pairs[:lintMaxExamples]
+	}
+	return []lintIssue{{level: "WARNING", message: fmt.Sprintf(
+		"near-duplicates: intent %s has pairs of texts with at least %.0f%% of their words in common",
+		intentLabel(intents), 100*lintNearDuplicate), examples: pairs}}
+}
+
+func jaccard(a map[string]bool, b map[string]bool) float64 {
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 1
+	}
+	return float64(common) / float64(union)
+}
+
+// lintConflicts finds identical texts that are annotated differently.
+func lintConflicts(examples []lintExample) []lintIssue {
+	annotations := make(map[string]map[string]bool)
+	for _, e := range examples {
+		if annotations[e.text] == nil {
+			annotations[e.text] = make(map[string]bool)
+		}
+		annotations[e.text][e.annotated] = true
+	}
+	var conflicts []string
+	count := 0
+	for _, text := range sortedKeys(annotations) {
+		if len(annotations[text]) < 2 {
+			continue
+		}
+		count++
+		if len(conflicts) < lintMaxExamples {
+			conflicts = append(conflicts, strings.Join(sortedKeys(annotations[text]), " | "))
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return []lintIssue{{level: "ERROR", message: fmt.Sprintf(
+		"conflicting annotations: %d texts are annotated in more than one way", count), examples: conflicts}}
+}
+
+// lintLength finds examples with more than lintMaxWords words. The examples are grouped by their masked
+// text, so that the templates producing them are shown.
+func lintLength(byIntent map[string][]lintExample) []lintIssue {
+	var issues []lintIssue
+	for _, intents := range sortedKeys(byIntent) {
+		long := make(map[string]int)
+		count := 0
+		for _, e := range byIntent[intents] {
+			if len(e.words) > lintMaxWords {
+				long[e.masked]++
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		masked := sortedKeys(long)
+		sort.SliceStable(masked, func(i, j int) bool {
+			return long[masked[i]] > long[masked[j]]
+		})
+		if len(masked) > lintMaxExamples {
+			masked = masked[:lintMaxExamples]
+		}
+		var examples []string
+		for _, m := range masked {
+			examples = append(examples, fmt.Sprintf("%s (%d examples)", m, long[m]))
+		}
+		issues = append(issues, lintIssue{level: "WARNING", message: fmt.Sprintf(
+			"over-long utterances: %d examples of intent %s have more than %d words",
+			count, intentLabel(intents), lintMaxWords), examples: examples})
+	}
+	return issues
+}
+
+// lintUnusedValues finds imported entity values that do not appear as entities in the sample.
+func lintUnusedValues(examples []lintExample, vocab *vocabulary) []lintIssue {
+	used := make(map[string]bool)
+	for _, e := range examples {
+		for _, v := range e.values {
+			used[v] = true
+		}
+	}
+	unused := make(map[string][]string)
+	totals := make(map[string]int)
+	for phrase, name := range vocab.phrases {
+		totals[name]++
+		if !used[phrase] {
+			unused[name] = append(unused[name], phrase)
+		}
+	}
+	var issues []lintIssue
+	for _, name := range sortedKeys(unused) {
+		values := unused[name]
+		sort.Strings(values)
+		if len(values) > lintMaxExamples {
+			values = values[:lintMaxExamples]
+		}
+		issues = append(issues, lintIssue{level: "WARNING", message: fmt.Sprintf(
+			"unused entity values: %d of the %d values imported as %s never appear in the sample",
+			len(unused[name]), totals[name], name), examples: values})
+	}
+	return issues
+}
+
+// lintFailed returns whether the issues should fail the check: any errors, or with strict any issues at all.
+func lintFailed(issues []lintIssue, strict bool) bool {
+	for _, issue := range issues {
+		if strict || issue.level == "ERROR" {
+			return true
+		}
+	}
+	return false
+}
+
+func printLintIssues(out io.Writer, issues []lintIssue) {
+	for _, issue := range issues {
+		fmt.Fprintf(out, "%s: %s\n", issue.level, issue.message)
+		for _, e := range issue.examples {
+			fmt.Fprintf(out, "└─ %s\n", e)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lintByIntent(examples []string) map[string][]lintExample {
+	byIntent := make(map[string][]lintExample)
+	for _, e := range parseLintExamples(examples) {
+		byIntent[e.intents] = append(byIntent[e.intents], e)
+	}
+	return byIntent
+}
+
+func repeatExamples(n int, examples ...string) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		result = append(result, examples[i%len(examples)])
+	}
+	return result
+}
+
+func TestLintImbalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name:     "balanced",
+			examples: append(repeatExamples(20, "*on turn on"), repeatExamples(2, "*off turn off")...),
+		},
+		{
+			name:     "imbalanced",
+			examples: append(repeatExamples(20, "*on turn on"), "*off turn off", "hello"),
+			expected: []lintIssue{
+				{level: "WARNING", message: "class imbalance: intent without intent has 1 examples, 5.0% of the 20 examples of on"},
+				{level: "WARNING", message: "class imbalance: intent off has 1 examples, 5.0% of the 20 examples of on"},
+			},
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, test := range tests {
+		if issues := lintImbalance(lintByIntent(test.examples)); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintDiversity(t *testing.T) {
+	var diverse []string
+	for i := 0; i < 100; i++ {
+		diverse = append(diverse, fmt.Sprintf("*a say word%d now", i))
+	}
+	repetitive := []string{"*a say one now", "*a say two now", "*a say three now", "*a say four now", "*a say five now"}
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name:     "diverse",
+			examples: diverse,
+		},
+		{
+			name:     "repetitive",
+			examples: repeatExamples(100, repetitive...),
+			expected: []lintIssue{{level: "WARNING", message: "low lexical diversity: 5.5% of the first 200 word bigrams of intent a are distinct",
+				examples: []string{"say one now", "say two now", "say three now"}}},
+		},
+		{
+			name:     "repetitive in a larger sample",
+			examples: repeatExamples(10000, repetitive...),
+			expected: []lintIssue{{level: "WARNING", message: "low lexical diversity: 5.5% of the first 200 word bigrams of intent a are distinct",
+				examples: []string{"say one now", "say two now", "say three now"}}},
+		},
+		{
+			name:     "too few bigrams",
+			examples: repeatExamples(60, "*a turn on"),
+		},
+	}
+	for _, test := range tests {
+		if issues := lintDiversity("a", parseLintExamples(test.examples)); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintNearDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name:     "near-duplicates",
+			examples: []string{"*a turn on the [lights](device) now", "*a turn on the [tv](device) now please", "*a what time is it"},
+			expected: []lintIssue{{level: "WARNING", message: "near-duplicates: intent a has pairs of texts with at least 80% of their words in common",
+				examples: []string{"turn on the <device> now ~ turn on the <device> now please"}}},
+		},
+		{
+			name:     "only different entity values",
+			examples: []string{"*a turn on the [lights](device)", "*a turn on the [tv](device)"},
+		},
+		{
+			name:     "different texts",
+			examples: []string{"*a turn on the [lights](device)", "*a switch off the [tv](device)"},
+		},
+	}
+	for _, test := range tests {
+		if issues := lintNearDuplicates("a", parseLintExamples(test.examples)); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name:     "conflicting",
+			examples: []string{"*a turn on the [lights](device)", "*a Turn on the lights", "*b turn on the [lights](device)", "*a turn on the [tv](device)"},
+			expected: []lintIssue{{level: "ERROR", message: "conflicting annotations: 1 texts are annotated in more than one way",
+				examples: []string{"*a Turn on the lights | *a turn on the [lights](device) | *b turn on the [lights](device)"}}},
+		},
+		{
+			name:     "identical",
+			examples: []string{"*a turn on the [lights](device)", "*a turn on the [lights](device)"},
+		},
+	}
+	for _, test := range tests {
+		if issues := lintConflicts(parseLintExamples(test.examples)); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintLength(t *testing.T) {
+	filler := strings.Repeat(" please", 30)
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name: "over-long",
+			examples: []string{
+				"*a turn on the [lights](device)" + filler,
+				"*a turn on the [tv](device)" + filler,
+				"*a turn off" + filler,
+				"*a turn on the [lights](device)",
+				"*b" + filler,
+			},
+			expected: []lintIssue{
+				{level: "WARNING", message: "over-long utterances: 3 examples of intent a have more than 30 words",
+					examples: []string{"turn on the <device>" + filler + " (2 examples)", "turn off" + filler + " (1 examples)"}},
+			},
+		},
+		{
+			name:     "exactly the maximum",
+			examples: []string{"*a" + filler},
+		},
+	}
+	for _, test := range tests {
+		if issues := lintLength(lintByIntent(test.examples)); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintUnusedValues(t *testing.T) {
+	vocab := &vocabulary{phrases: map[string]string{"lights": "device", "tv": "device", "radio": "device", "kitchen": "room"}, maxLen: 1}
+	tests := []struct {
+		name     string
+		examples []string
+		expected []lintIssue
+	}{
+		{
+			name:     "unused",
+			examples: []string{"*a turn on the [Lights](device)", "*a turn on the tv"},
+			expected: []lintIssue{
+				{level: "WARNING", message: "unused entity values: 2 of the 3 values imported as device never appear in the sample", examples: []string{"radio", "tv"}},
+				{level: "WARNING", message: "unused entity values: 1 of the 1 values imported as room never appear in the sample", examples: []string{"kitchen"}},
+			},
+		},
+		{
+			name:     "all used",
+			examples: []string{"*a turn on the [lights](device) [tv](device) and [radio](device) in the [kitchen](room)"},
+		},
+	}
+	for _, test := range tests {
+		if issues := lintUnusedValues(parseLintExamples(test.examples), vocab); !reflect.DeepEqual(test.expected, issues) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, issues)
+		}
+	}
+}
+
+func TestLintFailed(t *testing.T) {
+	warning := lintIssue{level: "WARNING"}
+	failure := lintIssue{level: "ERROR"}
+	tests := []struct {
+		issues   []lintIssue
+		strict   bool
+		expected bool
+	}{
+		{nil, false, false},
+		{nil, true, false},
+		{[]lintIssue{warning}, false, false},
+		{[]lintIssue{warning}, true, true},
+		{[]lintIssue{warning, failure}, false, true},
+	}
+	for _, test := range tests {
+		if result := lintFailed(test.issues, test.strict); result != test.expected {
+			t.Errorf("%v with strict %v: expected %v, got %v", test.issues, test.strict, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/speechly/cli/pkg/clients"
+)
+
+var loadCmd = &cobra.Command{
+	Use:   "load <app_id> <input_file>",
+	Short: "Load test the Streaming API with concurrent sessions",
+	Long:  "Opens the given number of concurrent Streaming API sessions, ramping them up linearly over --ramp-up. Each session streams the audio files of the corpus one after another at real-time pace (or faster with --speed) until --duration has passed after the ramp-up. The streams are spread over a pool of --connections connections.\n\nReports the errors by gRPC status code, histograms of the first word and final latencies, and the number of sessions that were sustained after the ramp-up.",
+	Example: `speechly bench load <app_id> corpus.jsonl --sessions 50 --ramp-up 30s --duration 5m
+speechly bench load <app_id> corpus.jsonl --sessions 200 --connections 8 --host localhost:9000 --max-error-rate 0.01`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		var opts loadOptions
+		opts.appID = args[0]
+		opts.sessions, _ = cmd.Flags().GetInt("sessions")
+		opts.rampUp, _ = cmd.Flags().GetDuration("ramp-up")
+		opts.duration, _ = cmd.Flags().GetDuration("duration")
+		opts.speed, _ = cmd.Flags().GetFloat64("speed")
+		chunk, _ := cmd.Flags().GetDuration("chunk")
+		connections, _ := cmd.Flags().GetInt("connections")
+		maxErrorRate, _ := cmd.Flags().GetFloat64("max-error-rate")
+		if opts.sessions < 1 || connections < 1 || chunk <= 0 || opts.speed < 0 {
+			log.Fatalf("Sessions, connections and chunk duration must be positive and speed non-negative")
+		}
+		opts.chunkSamples = int(chunk.Seconds() * streamSampleRate)
+		if host, _ := cmd.Flags().GetString("host"); host != "" {
+			ctx = clients.WithHost(ctx, host)
+		}
+
+		ac, err := readAudioCorpus(args[1])
+		if err != nil {
+			log.Fatalf("Reading corpus failed: %v", err)
+		}
+		for _, aci := range ac {
+			opts.audioFiles = append(opts.audioFiles, corpusAudioPath(args[1], aci))
+		}
+		if len(opts.audioFiles) == 0 {
+			log.Fatalf("No audio files in %s", args[1])
+		}
+
+		pool, closePool, err := clients.SLUClientPool(ctx, connections)
+		if err != nil {
+			log.Fatalf("Error connecting to API: %v", err)
+		}
+		defer closePool()
+
+		stats := runLoad(ctx, pool, opts)
+		if err := printLoadStats(cmd.OutOrStdout(), stats); err != nil {
+			log.Fatalf("Printing results failed: %v", err)
+		}
+		if rate := stats.errorRate(); cmd.Flags().Changed("max-error-rate") && rate > maxErrorRate {
+			log.Println("Load test failed")
+			log.Printf("└─ error rate %.4f is above the maximum %.4f", rate, maxErrorRate)
+			os.Exit(1)
+		}
+	},
+}
+
+type loadOptions struct {
+	appID        string
+	audioFiles   []string
+	sessions     int
+	rampUp       time.Duration
+	duration     time.Duration
+	speed        float64
+	chunkSamples int
+}
+
+// loadStats collects the results of all sessions of a load test.
+type loadStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	elapsed time.Duration
+	results []benchResult
+	codes   map[codes.Code]int
+	active  int
+	peak    int
+	// sustained holds the number of active sessions sampled every second after the ramp-up.
+	sustained []int
+}
+
+func (s *loadStats) streams() int {
+	total := 0
+	for _, n := range s.codes {
+		total += n
+	}
+	return total
+}
+
+func (s *loadStats) errorRate() float64 {
+	total := s.streams()
+	if total == 0 {
+		return 0
+	}
+	return float64(total-s.codes[codes.OK]) / float64(total)
+}
+
+func (s *loadStats) sessionStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active++
+	if s.active > s.peak {
+		s.peak = s.active
+	}
+}
+
+func (s *loadStats) sessionEnded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+}
+
+func (s *loadStats) add(r benchResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[status.Code(err)]++
+	if err == nil {
+		s.results = append(s.results, r)
+	}
+}
+
+// runLoad starts the sessions one by one over the ramp-up period. Each session streams audio files
+// until the end of the test; streams in progress at the end are allowed to finish.
+func runLoad(ctx context.Context, pool []sluv1.SLUClient, opts loadOptions) *loadStats {
+	stats := &loadStats{start: time.Now(), codes: make(map[codes.Code]int)}
+	rampEnd := stats.start.Add(opts.rampUp)
+	end := rampEnd.Add(opts.duration)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				stats.mu.Lock()
+				if now.After(rampEnd) && now.Before(end) {
+					stats.sustained = append(stats.sustained, stats.active)
+				}
+				if now.Sub(stats.start).Round(time.Second)%(10*time.Second) == 0 {
+					log.Printf("%v: %d active sessions, %d streams, %d errors", now.Sub(stats.start).Round(time.Second),
+						stats.active, stats.streams(), stats.streams()-stats.codes[codes.OK])
+				}
+				stats.mu.Unlock()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.sessions; i++ {
+		startAt := stats.start.Add(opts.rampUp * time.Duration(i) / time.Duration(opts.sessions))
+		time.Sleep(time.Until(startAt))
+		wg.Add(1)
+		go func(session int) {
+			defer wg.Done()
+			stats.sessionStarted()
+			defer stats.sessionEnded()
+			client := pool[session%len(pool)]
+			for n := session; time.Now().Before(end); n++ {
+				fn := opts.audioFiles[n%len(opts.audioFiles)]
+				r, err := benchStream(ctx, client, opts.appID, fn, opts.speed, opts.chunkSamples)
+				stats.add(r, err)
+				if err != nil && time.Now().Before(end) {
+					// Avoid busy looping when the server rejects all streams.
+					time.Sleep(100 * time.Millisecond)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	stats.elapsed = time.Since(stats.start)
+	return stats
+}
+
+var latencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	300 * time.Millisecond,
+	500 * time.Millisecond,
+	750 * time.Millisecond,
+	time.Second,
+	1500 * time.Millisecond,
+	2 * time.Second,
+	3 * time.Second,
+	5 * time.Second,
+}
+
+// histogram counts the durations in latencyBuckets. The last count is for durations above all buckets.
+func histogram(durations []time.Duration) []int {
+	counts := make([]int, len(latencyBuckets)+1)
+	for _, d := range durations {
+		i := 0
+		for i < len(latencyBuckets) && d > latencyBuckets[i] {
+			i++
+		}
+		counts[i]++
+	}
+	return counts
+}
+
+func printHistogram(out io.Writer, title string, durations []time.Duration) {
+	fmt.Fprintf(out, "\n%s\n", title)
+	counts := histogram(durations)
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	for i, c := range counts {
+		label := "> " + latencyBuckets[len(latencyBuckets)-1].String()
+		if i < len(latencyBuckets) {
+			label = "≤ " + latencyBuckets[i].String()
+		}
+		bar := ""
+		if max > 0 {
+			bar = strings.Repeat("█", c*40/max)
+		}
+		fmt.Fprintf(out, "%8s %6d %s\n", label, c, bar)
+	}
+}
+
+func printLoadStats(out io.Writer, stats *loadStats) error {
+	s := summarizeBench(stats.results, stats.elapsed)
+	if err := printBenchSummary(out, s); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nPeak sessions: %d\n", stats.peak)
+	if len(stats.sustained) > 0 {
+		min, sum := stats.sustained[0], 0
+		for _, n := range stats.sustained {
+			sum += n
+			if n < min {
+				min = n
+			}
+		}
+		fmt.Fprintf(out, "Sustained sessions after ramp-up: min %d, mean %.1f\n", min, float64(sum)/float64(len(stats.sustained)))
+	}
+
+	total := stats.streams()
+	fmt.Fprintf(out, "Error rate: %.4f (%d/%d)\n", stats.errorRate(), total-stats.codes[codes.OK], total)
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nSTATUS\tSTREAMS\tSHARE\n")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if n := stats.codes[c]; n > 0 {
+			fmt.Fprintf(w, "%s\t%d\t%.4f\n", c, n, float64(n)/float64(total))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	printHistogram(out, "FIRST WORD LATENCY", s.firstWord)
+	printHistogram(out, "FINAL LATENCY", s.final)
+	return nil
+}
+
+func init() {
+	benchCmd.AddCommand(loadCmd)
+	loadCmd.Flags().Int("sessions", 10, "Number of concurrent sessions.")
+	loadCmd.Flags().Duration("ramp-up", 10*time.Second, "Time over which the sessions are started.")
+	loadCmd.Flags().Duration("duration", time.Minute, "How long to keep all sessions running after the ramp-up.")
+	loadCmd.Flags().Int("connections", 4, "Number of connections the sessions are spread over.")
+	loadCmd.Flags().Float64("speed", 1, "Audio replay speed relative to real time. 0 sends the audio as fast as possible.")
+	loadCmd.Flags().Duration("chunk", 100*time.Millisecond, "Duration of the audio sent in a single message.")
+	loadCmd.Flags().String("host", "", "API host to load test instead of the host of the current project, e.g. localhost:9000.")
+	loadCmd.Flags().Float64("max-error-rate", 0, "Fail if the share of failed streams is above the given value.")
+}
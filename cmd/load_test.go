@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		expected  map[int]int
+	}{
+		{"empty", nil, nil},
+		{"bucket limits are inclusive", []time.Duration{0, 50 * time.Millisecond, 51 * time.Millisecond, 100 * time.Millisecond}, map[int]int{0: 2, 1: 2}},
+		{"above all buckets", []time.Duration{5 * time.Second, 5*time.Second + 1, time.Minute}, map[int]int{len(latencyBuckets) - 1: 1, len(latencyBuckets): 2}},
+	}
+	for _, test := range tests {
+		expected := make([]int, len(latencyBuckets)+1)
+		for i, n := range test.expected {
+			expected[i] = n
+		}
+		if counts := histogram(test.durations); !reflect.DeepEqual(expected, counts) {
+			t.Errorf("%s: expected %v, got %v", test.name, expected, counts)
+		}
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	s := &loadStats{codes: make(map[codes.Code]int)}
+	if s.errorRate() != 0 {
+		t.Errorf("Expected error rate 0 without streams, got %f", s.errorRate())
+	}
+
+	s.sessionStarted()
+	s.sessionStarted()
+	s.sessionEnded()
+	s.sessionStarted()
+	if s.active != 2 || s.peak != 2 {
+		t.Errorf("Expected 2 active and 2 peak sessions, got %d and %d", s.active, s.peak)
+	}
+
+	s.add(benchResult{final: time.Second}, nil)
+	s.add(benchResult{final: 2 * time.Second}, nil)
+	s.add(benchResult{final: 3 * time.Second}, status.Error(codes.ResourceExhausted, "too many streams"))
+	s.add(benchResult{}, finishedError(&sluv1.SLUError{Code: "INVALID_ARGUMENT", Message: "bad audio"}))
+	s.add(benchResult{}, errors.New("connection reset"))
+	expected := map[codes.Code]int{codes.OK: 2, codes.ResourceExhausted: 1, codes.InvalidArgument: 1, codes.Unknown: 1}
+	if !reflect.DeepEqual(expected, s.codes) {
+		t.Errorf("Expected codes %v, got %v", expected, s.codes)
+	}
+	if len(s.results) != 2 {
+		t.Errorf("Expected only the successful results to be kept, got %v", s.results)
+	}
+	if s.streams() != 5 || s.errorRate() != 0.6 {
+		t.Errorf("Expected error rate 3/5, got %f of %d", s.errorRate(), s.streams())
+	}
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/speechly/cli/pkg/sal"
+)
+
+// nluMatching selects which parts of the annotations must match in NLU evaluation.
+type nluMatching struct {
+	// ignoreCase compares the annotations case-insensitively.
+	ignoreCase bool
+	// ignoreValues compares entities without their normalized values.
+	ignoreValues bool
+	// ignoreBoundaries compares only the entity types of a segment, not which words they cover.
+	ignoreBoundaries bool
+	// ignoreIntent compares the annotations without the intents.
+	ignoreIntent bool
+	// unordered compares the segments of an utterance regardless of their order.
+	unordered bool
+}
+
+func addNLUMatchingFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("relax", false, "Ignore normalized entity values and casing in matching. Same as --ignore-case --ignore-values.")
+	cmd.Flags().Bool("ignore-case", false, "Ignore casing in matching.")
+	cmd.Flags().Bool("ignore-values", false, "Ignore normalized entity values in matching.")
+	cmd.Flags().Bool("ignore-entity-boundaries", false, "Match entities by type only, regardless of the words they cover.")
+	cmd.Flags().Bool("ignore-intent", false, "Ignore intents in matching.")
+	cmd.Flags().Bool("unordered-segments", false, "Match the segments of multi-intent utterances in any order.")
+}
+
+func readNLUMatching(cmd *cobra.Command) (nluMatching, error) {
+	var m nluMatching
+	relax, err := cmd.Flags().GetBool("relax")
+	if err != nil {
+		return m, err
+	}
+	for _, f := range []struct {
+		name  string
+		value *bool
+	}{
+		{"ignore-case", &m.ignoreCase},
+		{"ignore-values", &m.ignoreValues},
+		{"ignore-entity-boundaries", &m.ignoreBoundaries},
+		{"ignore-intent", &m.ignoreIntent},
+		{"unordered-segments", &m.unordered},
+	} {
+		if *f.value, err = cmd.Flags().GetBool(f.name); err != nil {
+			return m, err
+		}
+	}
+	if relax {
+		m.ignoreCase = true
+		m.ignoreValues = true
+	}
+	return m, nil
+}
+
+// normalize returns the annotated line in a form where two lines are equal if they match under the
+// options. Unparseable lines are returned as is, so that they are reported as mismatches.
+func (m nluMatching) normalize(line string) string {
+	if m.ignoreCase {
+		line = cases.Lower(language.AmericanEnglish).String(line)
+	}
+	u, err := sal.Parse(line)
+	if err != nil {
+		return line
+	}
+	if m.ignoreValues {
+		u = u.WithoutValues()
+	}
+	segments := make([]string, 0, len(u.Segments))
+	for _, s := range u.Segments {
+		if m.ignoreIntent {
+			s.Intent = ""
+		}
+		var str string
+		if m.ignoreBoundaries {
+			str = withoutBoundaries(s)
+		} else {
+			str = s.String()
+		}
+		if str != "" {
+			segments = append(segments, str)
+		}
+	}
+	if m.unordered {
+		sort.Strings(segments)
+	}
+	return strings.Join(segments, " ")
+}
+
+// withoutBoundaries formats the segment with the entities replaced by their words, followed by the
+// sorted entity types, e.g. "*turn_on turn on the kitchen lights [](device) [](room)".
+func withoutBoundaries(s sal.Segment) string {
+	var parts, types []string
+	if s.Intent != "" {
+		parts = append(parts, "*"+s.Intent)
+	}
+	for _, n := range s.Nodes {
+		if n.Kind != sal.EntityNode {
+			parts = append(parts, n.String())
+			continue
+		}
+		parts = append(parts, strings.Fields(n.Text)...)
+		types = append(types, sal.Node{Kind: sal.EntityNode, Value: n.Value, Type: n.Type}.String())
+	}
+	sort.Strings(types)
+	return strings.Join(append(parts, types...), " ")
+}
+
+// entityKey returns the parts of the entity that must match under the options.
+func (m nluMatching) entityKey(ent annotatedEntity) string {
+	key := ent.entityType
+	if !m.ignoreBoundaries {
+		key += "\x00" + ent.text
+	}
+	// Without boundaries, the default value of an entity is its text, which is not compared.
+	if !m.ignoreValues && !(m.ignoreBoundaries && ent.value == ent.text) {
+		key += "\x00" + ent.value
+	}
+	if m.ignoreCase {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// orderSegments reorders the predicted segments to pair them with the ground truth segments of the same
+// intent. Ground truth segments without a pair get an empty segment and the remaining predicted segments
+// are appended in their original order.
+func orderSegments(gt []annotatedSegment, pred []annotatedSegment) []annotatedSegment {
+	paired := make([]int, len(gt))
+	used := make([]bool, len(pred))
+	for j, g := range gt {
+		paired[j] = -1
+		for k, p := range pred {
+			if !used[k] && p.intent == g.intent {
+				paired[j] = k
+				used[k] = true
+				break
+			}
+		}
+	}
+	result := make([]annotatedSegment, 0, len(gt)+len(pred))
+	next := 0
+	for j := range gt {
+		if paired[j] < 0 {
+			for next < len(pred) && used[next] {
+				next++
+			}
+			if next < len(pred) {
+				paired[j] = next
+				used[next] = true
+			}
+		}
+		if paired[j] < 0 {
+			result = append(result, annotatedSegment{})
+		} else {
+			result = append(result, pred[paired[j]])
+		}
+	}
+	for k, p := range pred {
+		if !used[k] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// partialCredit scores how much of the ground truth annotation the prediction gets right, as the share
+// of matching intents and entities out of all intents and entities in either annotation. Utterances
+// without any intents or entities in both get full credit.
+func (m nluMatching) partialCredit(gt []annotatedSegment, pred []annotatedSegment) float64 {
+	gtParts, predParts := m.annotationParts(gt), m.annotationParts(pred)
+	total, matched := 0, 0
+	for _, n := range gtParts {
+		total += n
+	}
+	for part, n := range predParts {
+		total += n
+		if g := gtParts[part]; g < n {
+			matched += g
+		} else {
+			matched += n
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(matched) / float64(total-matched)
+}
+
+func (m nluMatching) annotationParts(segments []annotatedSegment) map[string]int {
+	parts := make(map[string]int)
+	for j, seg := range segments {
+		if !m.ignoreIntent && seg.intent != "" {
+			intent := seg.intent
+			if m.ignoreCase {
+				intent = strings.ToLower(intent)
+			}
+			if m.unordered {
+				parts["intent\x00"+intent]++
+			} else {
+				parts["intent\x00"+intent+"\x00"+strconv.Itoa(j)]++
+			}
+		}
+		for _, ent := range seg.entities {
+			parts["entity\x00"+m.entityKey(ent)]++
+		}
+	}
+	return parts
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/speechly/cli/pkg/sal"
+)
+
+func TestNormalize(t *testing.T) {
+	line := "*turn_on turn  on the [Kitchen|KITCHEN](room) [lights](device) *turn_off [tv](device)"
+	tests := []struct {
+		name     string
+		matching nluMatching
+		line     string
+		expected string
+	}{
+		{"exact", nluMatching{}, line, "*turn_on turn on the [Kitchen|KITCHEN](room) [lights](device) *turn_off [tv](device)"},
+		{"ignore case", nluMatching{ignoreCase: true}, line, "*turn_on turn on the [kitchen|kitchen](room) [lights](device) *turn_off [tv](device)"},
+		{"ignore values", nluMatching{ignoreValues: true}, line, "*turn_on turn on the [Kitchen](room) [lights](device) *turn_off [tv](device)"},
+		{"ignore boundaries", nluMatching{ignoreBoundaries: true}, line, "*turn_on turn on the Kitchen lights [](device) [|KITCHEN](room) *turn_off tv [](device)"},
+		{"ignore intent", nluMatching{ignoreIntent: true}, line, "turn on the [Kitchen|KITCHEN](room) [lights](device) [tv](device)"},
+		{"unordered", nluMatching{unordered: true}, line, "*turn_off [tv](device) *turn_on turn on the [Kitchen|KITCHEN](room) [lights](device)"},
+		{"relaxed", nluMatching{ignoreCase: true, ignoreValues: true}, line, "*turn_on turn on the [kitchen](room) [lights](device) *turn_off [tv](device)"},
+		{"values without boundaries", nluMatching{ignoreValues: true, ignoreBoundaries: true}, line, "*turn_on turn on the Kitchen lights [](device) [](room) *turn_off tv [](device)"},
+		{"unordered without intents", nluMatching{ignoreIntent: true, unordered: true}, line, "[tv](device) turn on the [Kitchen|KITCHEN](room) [lights](device)"},
+		{"all", nluMatching{true, true, true, true, true}, line, "turn on the kitchen lights [](device) [](room) tv [](device)"},
+		{"only an intent", nluMatching{ignoreIntent: true}, "*greet", ""},
+		{"unparseable", nluMatching{}, "*turn_on [lights", "*turn_on [lights"},
+		{"unparseable ignoring case", nluMatching{ignoreCase: true}, "*Turn_on [lights", "*turn_on [lights"},
+	}
+	for _, test := range tests {
+		if result := test.matching.normalize(test.line); result != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestNormalizeMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		matching nluMatching
+		expected bool
+	}{
+		{"different case", "*turn_on the [TV](device)", "*turn_on the [tv](device)", nluMatching{}, false},
+		{"different case ignored", "*turn_on the [TV](device)", "*turn_on the [tv](device)", nluMatching{ignoreCase: true}, true},
+		{"missing value", "*set [noon|12:00](time)", "*set [noon](time)", nluMatching{}, false},
+		{"missing value ignored", "*set [noon|12:00](time)", "*set [noon](time)", nluMatching{ignoreValues: true}, true},
+		{"different boundaries", "*turn_on the [kitchen lights](device)", "*turn_on the kitchen [lights](device)", nluMatching{}, false},
+		{"different boundaries ignored", "*turn_on the [kitchen lights](device)", "*turn_on the kitchen [lights](device)", nluMatching{ignoreBoundaries: true}, true},
+		{"different types with boundaries ignored", "*turn_on the [lights](device)", "*turn_on the [lights](room)", nluMatching{ignoreBoundaries: true}, false},
+		{"different intent", "*turn_on the [tv](device)", "*turn_off the [tv](device)", nluMatching{}, false},
+		{"different intent ignored", "*turn_on the [tv](device)", "*turn_off the [tv](device)", nluMatching{ignoreIntent: true}, true},
+		{"segment order", "*turn_on [tv](device) *turn_off [radio](device)", "*turn_off [radio](device) *turn_on [tv](device)", nluMatching{}, false},
+		{"segment order ignored", "*turn_on [tv](device) *turn_off [radio](device)", "*turn_off [radio](device) *turn_on [tv](device)", nluMatching{unordered: true}, true},
+		{"extra spaces", "*turn_on  the [ tv ](device)", "*turn_on the [tv](device)", nluMatching{}, true},
+	}
+	for _, test := range tests {
+		if result := test.matching.normalize(test.a) == test.matching.normalize(test.b); result != test.expected {
+			t.Errorf("%s: expected match %v, got %v", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestWithoutBoundaries(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"*turn_on turn on the [kitchen](room) [living room lights|LIGHTS](device)", "*turn_on turn on the kitchen living room lights [](room) [|LIGHTS](device)"},
+		{"hello (uh) there", "hello (uh) there"},
+		{"*greet", "*greet"},
+	}
+	for _, test := range tests {
+		if result := withoutBoundaries(sal.MustParse(test.line).Segments[0]); result != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, result)
+		}
+	}
+}
+
+func TestEntityKey(t *testing.T) {
+	kitchen := annotatedEntity{entityType: "room", text: "Kitchen", value: "KITCHEN"}
+	lights := annotatedEntity{entityType: "device", text: "Lights", value: "Lights"}
+	tests := []struct {
+		name     string
+		matching nluMatching
+		entity   annotatedEntity
+		expected string
+	}{
+		{"exact", nluMatching{}, kitchen, "room\x00Kitchen\x00KITCHEN"},
+		{"ignore case", nluMatching{ignoreCase: true}, kitchen, "room\x00kitchen\x00kitchen"},
+		{"ignore values", nluMatching{ignoreValues: true}, kitchen, "room\x00Kitchen"},
+		{"ignore boundaries", nluMatching{ignoreBoundaries: true}, kitchen, "room\x00KITCHEN"},
+		{"ignore boundaries and values", nluMatching{ignoreBoundaries: true, ignoreValues: true}, kitchen, "room"},
+		{"ignore boundaries of a default value", nluMatching{ignoreBoundaries: true}, lights, "device"},
+		{"default value", nluMatching{}, lights, "device\x00Lights\x00Lights"},
+	}
+	for _, test := range tests {
+		if result := test.matching.entityKey(test.entity); result != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestOrderSegments(t *testing.T) {
+	segs := func(intents ...string) []annotatedSegment {
+		var s []annotatedSegment
+		for _, i := range intents {
+			s = append(s, annotatedSegment{intent: i})
+		}
+		return s
+	}
+	tests := []struct {
+		name     string
+		gt       []annotatedSegment
+		pred     []annotatedSegment
+		expected []annotatedSegment
+	}{
+		{"same order", segs("a", "b"), segs("a", "b"), segs("a", "b")},
+		{"reordered", segs("a", "b", "c"), segs("c", "a", "b"), segs("a", "b", "c")},
+		{"unpaired segment takes the next unused prediction", segs("a", "b", "c"), segs("c", "x", "a"), segs("a", "x", "c")},
+		{"extra predictions are appended", segs("a"), segs("b", "a", "c"), segs("a", "b", "c")},
+		{"missing predictions are empty", segs("a", "b"), segs("b"), segs("", "b")},
+		{"no predictions", segs("a"), nil, segs("")},
+	}
+	for _, test := range tests {
+		if result := orderSegments(test.gt, test.pred); !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestPartialCredit(t *testing.T) {
+	seg := func(intent string, entities ...annotatedEntity) annotatedSegment {
+		return annotatedSegment{intent: intent, entities: entities}
+	}
+	tv := annotatedEntity{entityType: "device", text: "tv", value: "tv"}
+	radio := annotatedEntity{entityType: "device", text: "radio", value: "radio"}
+	tvValue := annotatedEntity{entityType: "device", text: "tv", value: "TV"}
+	tests := []struct {
+		name     string
+		matching nluMatching
+		gt       []annotatedSegment
+		pred     []annotatedSegment
+		expected float64
+	}{
+		{"correct", nluMatching{}, []annotatedSegment{seg("turn_on", tv)}, []annotatedSegment{seg("turn_on", tv)}, 1},
+		{"wrong entity", nluMatching{}, []annotatedSegment{seg("turn_on", tv)}, []annotatedSegment{seg("turn_on", radio)}, 1.0 / 3},
+		{"extra entity", nluMatching{}, []annotatedSegment{seg("turn_on", tv)}, []annotatedSegment{seg("turn_on", tv, radio)}, 2.0 / 3},
+		{"nothing predicted", nluMatching{}, []annotatedSegment{seg("turn_on", tv)}, nil, 0},
+		{"nothing to predict", nluMatching{}, []annotatedSegment{seg("")}, []annotatedSegment{seg("")}, 1},
+		{"wrong value", nluMatching{}, []annotatedSegment{seg("turn_on", tvValue)}, []annotatedSegment{seg("turn_on", tv)}, 1.0 / 3},
+		{"wrong value ignored", nluMatching{ignoreValues: true}, []annotatedSegment{seg("turn_on", tvValue)}, []annotatedSegment{seg("turn_on", tv)}, 1},
+		{"wrong intent", nluMatching{}, []annotatedSegment{seg("turn_on", tv)}, []annotatedSegment{seg("turn_off", tv)}, 1.0 / 3},
+		{"wrong intent ignored", nluMatching{ignoreIntent: true}, []annotatedSegment{seg("turn_on", tv)}, []annotatedSegment{seg("turn_off", tv)}, 1},
+		{"intent case", nluMatching{}, []annotatedSegment{seg("Turn_On")}, []annotatedSegment{seg("turn_on")}, 0},
+		{"intent case ignored", nluMatching{ignoreCase: true}, []annotatedSegment{seg("Turn_On")}, []annotatedSegment{seg("turn_on")}, 1},
+		{"segment order", nluMatching{}, []annotatedSegment{seg("a"), seg("b")}, []annotatedSegment{seg("b"), seg("a")}, 0},
+		{"segment order ignored", nluMatching{unordered: true}, []annotatedSegment{seg("a"), seg("b")}, []annotatedSegment{seg("b"), seg("a")}, 1},
+	}
+	for _, test := range tests {
+		if result := test.matching.partialCredit(test.gt, test.pred); math.Abs(result-test.expected) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestAnnotationParts(t *testing.T) {
+	segments := []annotatedSegment{
+		{intent: "turn_on", entities: []annotatedEntity{{"device", "tv", "tv"}, {"device", "tv", "tv"}}},
+		{intent: "Turn_On"},
+		{},
+	}
+	tests := []struct {
+		name     string
+		matching nluMatching
+		expected map[string]int
+	}{
+		{"exact", nluMatching{}, map[string]int{"intent\x00turn_on\x000": 1, "intent\x00Turn_On\x001": 1, "entity\x00device\x00tv\x00tv": 2}},
+		{"unordered ignoring case", nluMatching{ignoreCase: true, unordered: true}, map[string]int{"intent\x00turn_on": 2, "entity\x00device\x00tv\x00tv": 2}},
+		{"ignore intent", nluMatching{ignoreIntent: true, ignoreValues: true}, map[string]int{"entity\x00device\x00tv": 2}},
+	}
+	for _, test := range tests {
+		if result := test.matching.annotationParts(segments); !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, result)
+		}
+	}
+}
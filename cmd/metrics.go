@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	wluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"github.com/speechly/cli/pkg/sal"
+)
+
+const noIntent = "<none>"
+
+type annotatedSegment struct {
+	intent   string
+	entities []annotatedEntity
+}
+
+type annotatedEntity struct {
+	entityType string
+	text       string
+	value      string
+}
+
+// PRF contains the precision, recall and F1 score of a single entity type.
+type PRF struct {
+	TP        int     `json:"tp"`
+	FP        int     `json:"fp"`
+	FN        int     `json:"fn"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+func (p PRF) compute() PRF {
+	if p.TP+p.FP > 0 {
+		p.Precision = float64(p.TP) / float64(p.TP+p.FP)
+	}
+	if p.TP+p.FN > 0 {
+		p.Recall = float64(p.TP) / float64(p.TP+p.FN)
+	}
+	if p.Precision+p.Recall > 0 {
+		p.F1 = 2 * p.Precision * p.Recall / (p.Precision + p.Recall)
+	}
+	return p
+}
+
+// EntityMetrics compares entities both by their surface text and by their normalized value.
+type EntityMetrics struct {
+	Text  PRF `json:"text"`
+	Value PRF `json:"value"`
+}
+
+// NLUMetrics contains intent and entity level metrics of an NLU evaluation.
+type NLUMetrics struct {
+	IntentHits     int                       `json:"intent_hits"`
+	IntentTotal    int                       `json:"intent_total"`
+	IntentAccuracy float64                   `json:"intent_accuracy"`
+	Confusion      map[string]map[string]int `json:"intent_confusion,omitempty"`
+	Entities       map[string]EntityMetrics  `json:"entity_metrics,omitempty"`
+	// PartialCredit is the mean share of matching intents and entities per utterance.
+	PartialCredit float64 `json:"partial_credit"`
+}
+
+func parseAnnotatedUtterances(lines []string) ([][]annotatedSegment, error) {
+	result := make([][]annotatedSegment, len(lines))
+	for i, line := range lines {
+		u, err := sal.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		for _, s := range u.Segments {
+			segment := annotatedSegment{intent: s.Intent}
+			for _, ent := range s.Entities() {
+				segment.entities = append(segment.entities, annotatedEntity{
+					entityType: ent.Type,
+					text:       ent.Text,
+					value:      ent.NormalizedValue(),
+				})
+			}
+			result[i] = append(result[i], segment)
+		}
+	}
+	return result, nil
+}
+
+func wluResponsesToSegments(responses []*wluv1.WLUResponse) [][]annotatedSegment {
+	result := make([][]annotatedSegment, len(responses))
+	for i, resp := range responses {
+		for _, seg := range resp.GetSegments() {
+			segment := annotatedSegment{intent: seg.GetIntent().GetIntent()}
+			for _, ent := range seg.GetEntities() {
+				var words []string
+				for _, tok := range seg.GetTokens() {
+					if tok.Index >= ent.GetStartPosition() && tok.Index < ent.GetEndPosition() {
+						words = append(words, tok.Word)
+					}
+				}
+				segment.entities = append(segment.entities, annotatedEntity{
+					entityType: ent.GetEntity(),
+					text:       strings.Join(words, " "),
+					value:      ent.GetValue(),
+				})
+			}
+			result[i] = append(result[i], segment)
+		}
+	}
+	return result
+}
+
+// compareAnnotations matches predicted segments to the ground truth segment by segment for intents, and
+// utterance by utterance for entities.
+func compareAnnotations(predicted [][]annotatedSegment, groundTruth [][]annotatedSegment, matching nluMatching) NLUMetrics {
+	m := NLUMetrics{
+		Confusion: make(map[string]map[string]int),
+		Entities:  make(map[string]EntityMetrics),
+	}
+	norm := func(s string) string {
+		if matching.ignoreCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	credit := 0.0
+	for i, gt := range groundTruth {
+		var pred []annotatedSegment
+		if i < len(predicted) {
+			pred = predicted[i]
+		}
+		if matching.unordered {
+			pred = orderSegments(gt, pred)
+		}
+		credit += matching.partialCredit(gt, pred)
+		for j := 0; j < len(gt) || j < len(pred); j++ {
+			gtIntent, predIntent := noIntent, noIntent
+			if j < len(gt) && gt[j].intent != "" {
+				gtIntent = gt[j].intent
+			}
+			if j < len(pred) && pred[j].intent != "" {
+				predIntent = pred[j].intent
+			}
+			if _, ok := m.Confusion[gtIntent]; !ok {
+				m.Confusion[gtIntent] = make(map[string]int)
+			}
+			m.Confusion[gtIntent][predIntent] += 1
+			if gtIntent != noIntent {
+				m.IntentTotal += 1
+				if gtIntent == predIntent {
+					m.IntentHits += 1
+				}
+			}
+		}
+
+		gtTexts, gtValues := countEntities(gt, norm, matching.ignoreBoundaries)
+		predTexts, predValues := countEntities(pred, norm, matching.ignoreBoundaries)
+		for entType := range unionKeys(gtTexts, predTexts) {
+			em := m.Entities[entType]
+			em.Text = matchEntities(em.Text, gtTexts[entType], predTexts[entType])
+			em.Value = matchEntities(em.Value, gtValues[entType], predValues[entType])
+			m.Entities[entType] = em
+		}
+	}
+	if m.IntentTotal > 0 {
+		m.IntentAccuracy = float64(m.IntentHits) / float64(m.IntentTotal)
+	}
+	if len(groundTruth) > 0 {
+		m.PartialCredit = credit / float64(len(groundTruth))
+	}
+	for entType, em := range m.Entities {
+		m.Entities[entType] = EntityMetrics{Text: em.Text.compute(), Value: em.Value.compute()}
+	}
+	return m
+}
+
+// countEntities counts the entity texts and values by entity type. Without boundaries, the texts are not
+// compared, so all entities of a type are counted under the empty text.
+func countEntities(segments []annotatedSegment, norm func(string) string, ignoreBoundaries bool) (map[string]map[string]int, map[string]map[string]int) {
+	texts := make(map[string]map[string]int)
+	values := make(map[string]map[string]int)
+	for _, seg := range segments {
+		for _, ent := range seg.entities {
+			if _, ok := texts[ent.entityType]; !ok {
+				texts[ent.entityType] = make(map[string]int)
+				values[ent.entityType] = make(map[string]int)
+			}
+			text := ent.text
+			if ignoreBoundaries {
+				text = ""
+			}
+			texts[ent.entityType][norm(text)] += 1
+			values[ent.entityType][norm(ent.value)] += 1
+		}
+	}
+	return texts, values
+}
+
+func matchEntities(p PRF, gt map[string]int, pred map[string]int) PRF {
+	for val, n := range gt {
+		tp := n
+		if pred[val] < tp {
+			tp = pred[val]
+		}
+		p.TP += tp
+		p.FN += n - tp
+	}
+	for val, n := range pred {
+		tp := n
+		if gt[val] < tp {
+			tp = gt[val]
+		}
+		p.FP += n - tp
+	}
+	return p
+}
+
+func unionKeys[V any](a map[string]V, b map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+func printNLUMetrics(out io.Writer, m NLUMetrics) error {
+	fmt.Fprintf(out, "Intent accuracy: %.2f (%d/%d)\n", m.IntentAccuracy, m.IntentHits, m.IntentTotal)
+	fmt.Fprintf(out, "Partial credit: %.2f\n", m.PartialCredit)
+
+	labels := make(map[string]bool)
+	for gt, row := range m.Confusion {
+		labels[gt] = true
+		for pred := range row {
+			labels[pred] = true
+		}
+	}
+	columns := make([]string, 0, len(labels))
+	for l := range labels {
+		if l != noIntent {
+			columns = append(columns, l)
+		}
+	}
+	sort.Strings(columns)
+	if labels[noIntent] {
+		columns = append(columns, noIntent)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	fmt.Fprint(w, "\nGROUND TRUTH \\ PREDICTION\t"+strings.Join(columns, "\t")+"\n")
+	for _, gt := range columns {
+		row, ok := m.Confusion[gt]
+		if !ok {
+			continue
+		}
+		counts := make([]string, len(columns))
+		for i, pred := range columns {
+			counts[i] = fmt.Sprint(row[pred])
+		}
+		fmt.Fprint(w, gt+"\t"+strings.Join(counts, "\t")+"\n")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(m.Entities) == 0 {
+		return nil
+	}
+	fmt.Fprint(w, "\nENTITY TYPE\tPRECISION\tRECALL\tF1\tVALUE PRECISION\tVALUE RECALL\tVALUE F1\n")
+	for _, entType := range sortedKeys(m.Entities) {
+		em := m.Entities[entType]
+		fmt.Fprintf(w, "%s\t%f\t%f\t%f\t%f\t%f\t%f\n", entType,
+			em.Text.Precision, em.Text.Recall, em.Text.F1, em.Value.Precision, em.Value.Recall, em.Value.F1)
+	}
+	return w.Flush()
+}
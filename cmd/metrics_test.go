@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMatchEntities(t *testing.T) {
+	tests := []struct {
+		name     string
+		gt       map[string]int
+		pred     map[string]int
+		expected PRF
+	}{
+		{"all correct", map[string]int{"lights": 2, "tv": 1}, map[string]int{"lights": 2, "tv": 1}, PRF{TP: 3}},
+		{"missed", map[string]int{"lights": 2, "tv": 1}, map[string]int{"lights": 1}, PRF{TP: 1, FN: 2}},
+		{"spurious", map[string]int{"lights": 1}, map[string]int{"lights": 3, "radio": 1}, PRF{TP: 1, FP: 3}},
+		{"wrong", map[string]int{"kitchen": 1}, map[string]int{"hall": 1}, PRF{FP: 1, FN: 1}},
+		{"empty", nil, nil, PRF{}},
+	}
+	for _, test := range tests {
+		if p := matchEntities(PRF{}, test.gt, test.pred); p != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, p)
+		}
+	}
+
+	p := matchEntities(PRF{TP: 1, FP: 1, FN: 2}, map[string]int{"a": 1}, map[string]int{"a": 1}).compute()
+	if p.TP != 2 || p.FP != 1 || p.FN != 2 {
+		t.Errorf("Expected the counts to accumulate, got %+v", p)
+	}
+	if math.Abs(p.Precision-2.0/3.0) > 1e-9 || math.Abs(p.Recall-0.5) > 1e-9 || math.Abs(p.F1-4.0/7.0) > 1e-9 {
+		t.Errorf("Expected precision 2/3, recall 1/2 and F1 4/7, got %+v", p)
+	}
+}
+
+func TestCompareAnnotations(t *testing.T) {
+	groundTruth := []string{
+		"*turn_on turn on the [lights](device) in the [kitchen](room)",
+		"*turn_off turn off the [tv](device) *turn_on turn on the [radio](device)",
+		"*set set the alarm for [tomorrow|2021-01-02](date)",
+		"hello there",
+	}
+	predictions := []string{
+		"*turn_on turn on the [lights](device) in the [hall](room)",
+		"*turn_off turn off the [tv](device)",
+		"*set set the alarm for [tomorrow|2021-01-03](date)",
+		"*greet hello there",
+	}
+	gt, err := parseAnnotatedUtterances(groundTruth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pred, err := parseAnnotatedUtterances(predictions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := compareAnnotations(pred, gt, nluMatching{})
+
+	if m.IntentHits != 3 || m.IntentTotal != 4 || m.IntentAccuracy != 0.75 {
+		t.Errorf("Expected intent accuracy 3/4, got %d/%d = %f", m.IntentHits, m.IntentTotal, m.IntentAccuracy)
+	}
+	confusion := map[string]map[string]int{
+		"turn_on":  {"turn_on": 1, noIntent: 1},
+		"turn_off": {"turn_off": 1},
+		"set":      {"set": 1},
+		noIntent:   {"greet": 1},
+	}
+	if !reflect.DeepEqual(confusion, m.Confusion) {
+		t.Errorf("Expected confusion %v, got %v", confusion, m.Confusion)
+	}
+
+	entities := map[string]struct{ text, value PRF }{
+		"device": {PRF{TP: 2, FN: 1}, PRF{TP: 2, FN: 1}},
+		"room":   {PRF{FP: 1, FN: 1}, PRF{FP: 1, FN: 1}},
+		"date":   {PRF{TP: 1}, PRF{FP: 1, FN: 1}},
+	}
+	if len(m.Entities) != len(entities) {
+		t.Errorf("Expected metrics for %d entity types, got %v", len(entities), m.Entities)
+	}
+	for entType, e := range entities {
+		em := m.Entities[entType]
+		text, value := em.Text, em.Value
+		text.Precision, text.Recall, text.F1 = 0, 0, 0
+		value.Precision, value.Recall, value.F1 = 0, 0, 0
+		if text != e.text || value != e.value {
+			t.Errorf("Expected %s text %+v and value %+v, got %+v and %+v", entType, e.text, e.value, em.Text, em.Value)
+		}
+	}
+	if device := m.Entities["device"].Text; device.Precision != 1 || math.Abs(device.Recall-2.0/3.0) > 1e-9 || math.Abs(device.F1-0.8) > 1e-9 {
+		t.Errorf("Expected device precision 1, recall 2/3 and F1 0.8, got %+v", device)
+	}
+}
@@ -1,18 +1,22 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"regexp"
+	"os"
 	"sort"
+	"strconv"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	salv1 "github.com/speechly/api/go/speechly/sal/v1"
 	"github.com/speechly/cli/pkg/clients"
+	"github.com/speechly/cli/pkg/sal"
 	"github.com/speechly/cli/pkg/upload"
 )
 
@@ -36,10 +40,14 @@ func (u CompileWriter) Write(data []byte) (n int, err error) {
 var sampleCmd = &cobra.Command{
 	Use:   "sample",
 	Short: "Sample a set of examples from the given SAL configuration",
-	Long:  "The contents of the directory given as argument is sent to the API and compiled. If configuration is valid, a set of examples are printed to stdout.",
+	Long:  "The contents of the directory given as argument is sent to the API and compiled. If configuration is valid, a set of examples are printed to stdout.\n\nA single compilation returns at most 10000 examples. With --total, the configuration is compiled repeatedly with seeds derived from --seed until the given number of examples has been collected. The examples are printed as each batch arrives, and the statistics are computed over all batches.\n\nWith --export-eval, the examples are split into a training and a test set instead of printing them. The split is stratified by intent, and identical examples always end up in the same set. The annotated test set can be used as ground truth with `evaluate nlu`, and the sets without annotations as input to `annotate`.\n\nWith --lint, the sample is checked for common dataset quality issues: intents with less than 10% of the examples of the most common intent, intents with few distinct word bigrams, templates that produce nearly the same text, identical texts with different annotations, utterances longer than 30 words and imported entity values that do not appear in the sample. Exits with status 1 if errors are found, or with --lint-strict if any issues are found. Use --total to check a larger sample.",
 	Example: `speechly sample <app_id> .
 speechly sample --app <app_id> /path/to/config
-speechly sample <app_id> /path/to/config --stats`,
+speechly sample <app_id> /path/to/config --stats
+speechly sample <app_id> /path/to/config --batch-size 1000 --stats-format json > stats.json
+speechly sample <app_id> /path/to/config --total 100000 --stats
+speechly sample <app_id> /path/to/config --batch-size 5000 --export-eval eval --test-ratio 0.1
+speechly sample <app_id> /path/to/config --total 50000 --lint`,
 	Args: cobra.RangeArgs(1, 2),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		appId, _ := cmd.Flags().GetString("app")
@@ -58,67 +66,205 @@ speechly sample <app_id> /path/to/config --stats`,
 			appId = args[0]
 			inputDir = args[1]
 		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if err := printUploadFiles(cmd.OutOrStdout(), inputDir); err != nil {
+				log.Fatalf("Listing files failed: %s", err)
+			}
+			return
+		}
 		batchSize, _ := cmd.Flags().GetInt("batch-size")
 		if batchSize < 32 || batchSize > 10000 {
 			log.Fatal("Batch size must be between 32 and 10000")
 		}
 		seed, _ := cmd.Flags().GetInt("seed")
+		switch statsFormat, _ := cmd.Flags().GetString("stats-format"); statsFormat {
+		case "table", "json", "csv":
+		default:
+			log.Fatalf("Unknown stats format %s, expected table, json or csv", statsFormat)
+		}
 
-		uploadData := upload.CreateTarFromDir(inputDir)
+		total, _ := cmd.Flags().GetInt("total")
+		if total < 0 {
+			log.Fatal("Total must not be negative")
+		}
+		keepDuplicates, _ := cmd.Flags().GetBool("keep-duplicates")
+
+		simpleStats, _ := cmd.Flags().GetBool("stats")
+		advancedStats, _ := cmd.Flags().GetBool("advanced-stats")
+		limit, _ := cmd.Flags().GetInt("advanced-stats-limit")
+		statsFormat, _ := cmd.Flags().GetString("stats-format")
+		if statsFormat != "table" && !simpleStats && !advancedStats {
+			simpleStats, advancedStats = true, true
+		}
 
-		if len(uploadData.Files) == 0 {
-			log.Fatal("No files to upload!\n\nPlease ensure the files are named *.yaml or *.csv")
+		lint, _ := cmd.Flags().GetBool("lint")
+		lintStrict, _ := cmd.Flags().GetBool("lint-strict")
+		exportDir, _ := cmd.Flags().GetString("export-eval")
+		testRatio, _ := cmd.Flags().GetFloat64("test-ratio")
+		splitSeed, _ := cmd.Flags().GetInt64("split-seed")
+		if testRatio < 0 || testRatio > 1 {
+			log.Fatal("Test ratio must be between 0 and 1")
 		}
 
-		// open a stream for upload
-		compileClient, err := clients.CompileClient(ctx)
-		if err != nil {
-			log.Fatalf("Error connecting to API: %s", err)
+		// Examples are printed as they arrive, unless they are needed for the statistics or the export.
+		var examples []string
+		emit := func(batch []string) {
+			if simpleStats || advancedStats || exportDir != "" || lint {
+				examples = append(examples, batch...)
+				return
+			}
+			for _, message := range batch {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\n", message)
+			}
 		}
-		stream, err := compileClient.Compile(ctx)
+
+		compile, err := sampleCompiler(ctx, appId, inputDir, batchSize, total > 0)
 		if err != nil {
-			log.Fatalf("Failed to open validate stream: %s", err)
+			log.Fatalf("Sampling failed: %s", err)
+		}
+		var messages []*salv1.LineReference
+		if total > 0 {
+			log.Printf("Sampling %d examples in batches of %d\n", total, batchSize)
+			messages, err = sampleTotal(compile, seed, total, keepDuplicates, emit)
+			if err != nil {
+				log.Fatalf("Sampling failed: %s", err)
+			}
+		} else {
+			compileResult, err := compile(seed)
+			if err != nil {
+				log.Fatalf("Sampling failed: %s", err)
+			}
+			messages = compileResult.Messages
+			if len(messages) == 0 {
+				emit(compileResult.Templates)
+			}
 		}
 
-		// flush the tar from memory to the stream
-		compileWriter := CompileWriter{appId, stream, int32(batchSize), int32(seed)}
-		_, err = uploadData.Buf.WriteTo(compileWriter)
-		if err != nil {
-			log.Fatalf("Streaming file data failed: %s", err)
+		if len(messages) > 0 {
+			printLineErrors(messages)
+			return
 		}
-		log.Printf("Sampling %d examples \n", batchSize)
+		if exportDir != "" {
+			if err := exportEvalSets(exportDir, examples, testRatio, splitSeed); err != nil {
+				log.Fatalf("Exporting evaluation sets failed: %s", err)
+			}
+		}
+		if simpleStats || advancedStats {
+			if err := printStats(cmd.OutOrStdout(), examples, simpleStats, advancedStats, int32(limit), statsFormat); err != nil {
+				log.Fatalf("Printing statistics failed: %s", err)
+			}
+		}
+		if lint {
+			vocab, err := readImports(inputDir)
+			if err != nil {
+				log.Printf("Not checking imported entity values: %s", err)
+			}
+			issues := lintExamples(examples, vocab)
+			printLintIssues(cmd.OutOrStdout(), issues)
+			if len(issues) > 0 {
+				log.Printf("Found %d issues in %d examples", len(issues), len(examples))
+			}
+			if lintFailed(issues, lintStrict) {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// compileFunc compiles a configuration with the given seed and returns a sample of examples.
+type compileFunc func(seed int) (*salv1.CompileResult, error)
+
+// sampleCompiler lists the configuration files in the directory once, and returns a function that compiles
+// them into samples of batchSize examples. With quiet, the upload of the files is not shown, so that many
+// batches can be compiled without repeating the progress bar and logs for each.
+func sampleCompiler(ctx context.Context, appId string, inputDir string, batchSize int, quiet bool) (compileFunc, error) {
+	uploadData := upload.CreateTarFromDir(inputDir)
+	if len(uploadData.Files) == 0 {
+		return nil, fmt.Errorf("no files to upload from %s, ensure the files are named *.yaml or *.csv", inputDir)
+	}
+	return func(seed int) (*salv1.CompileResult, error) {
+		return compileSample(ctx, appId, uploadData, batchSize, seed, quiet)
+	}, nil
+}
 
-		compileResult, err := stream.CloseAndRecv()
+// sampleTotal compiles batches with the seeds seed, seed+1, ... until total examples have been
+// collected, and passes the examples of each batch to emit as soon as it arrives. Unless keepDuplicates is
+// set, examples already seen are skipped. Sampling stops early if a batch has no new examples. If the
+// compilation fails, the messages of the compiler are returned.
+func sampleTotal(compile compileFunc, seed int, total int, keepDuplicates bool, emit func([]string)) ([]*salv1.LineReference, error) {
+	seen := make(map[string]bool)
+	n := 0
+	for i := 0; n < total; i++ {
+		compileResult, err := compile(seed + i)
 		if err != nil {
-			log.Fatalf("Validate failed: %s", err)
+			return nil, err
 		}
-
 		if len(compileResult.Messages) > 0 {
-			printLineErrors(compileResult.Messages)
-		} else {
-			simpleStats, _ := cmd.Flags().GetBool("stats")
-			advancedStats, _ := cmd.Flags().GetBool("advanced-stats")
-			limit, _ := cmd.Flags().GetInt("advanced-stats-limit")
-			if simpleStats || advancedStats {
-				printStats(cmd.OutOrStdout(), compileResult.Templates, simpleStats, advancedStats, int32(limit))
-			} else {
-				for _, message := range compileResult.Templates {
-					fmt.Fprintf(cmd.OutOrStdout(), "%s\n", message)
+			return compileResult.Messages, nil
+		}
+		batch := make([]string, 0, len(compileResult.Templates))
+		for _, example := range compileResult.Templates {
+			if n+len(batch) == total {
+				break
+			}
+			if !keepDuplicates {
+				if seen[example] {
+					continue
 				}
+				seen[example] = true
 			}
+			batch = append(batch, example)
 		}
-	},
+		if len(batch) == 0 {
+			log.Printf("No new examples in batch %d, stopping at %d examples", i+1, n)
+			break
+		}
+		n += len(batch)
+		emit(batch)
+	}
+	return nil, nil
+}
+
+// compileSample compiles the configuration files and returns a sample of batchSize examples. Unless quiet
+// is set, the progress of the upload is shown.
+func compileSample(ctx context.Context, appId string, uploadData upload.UploadData, batchSize int, seed int, quiet bool) (*salv1.CompileResult, error) {
+	// open a stream for upload
+	compileClient, err := clients.CompileClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to API: %s", err)
+	}
+	stream, err := compileClient.Compile(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compile stream: %s", err)
+	}
+
+	// stream the tar package of the files to the API
+	compileWriter := CompileWriter{appId, stream, int32(batchSize), int32(seed)}
+	if quiet {
+		_, _, err = uploadData.Stream(compileWriter, io.Discard)
+	} else {
+		_, _, err = streamUploadData(uploadData, compileWriter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("streaming file data failed: %s", err)
+	}
+	if !quiet {
+		log.Printf("Sampling %d examples \n", batchSize)
+	}
+
+	compileResult, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("compile failed: %s", err)
+	}
+	return compileResult, nil
 }
 
 type IntentEntityCounter struct {
-	entityCounts      map[string]map[string]map[string]float32
-	intentCounts      map[string]float32
-	intentsRe         *regexp.Regexp
-	entityRe          *regexp.Regexp
-	utteranceCnt      float32
-	entityToInt       map[string]int
-	entityToType      map[string]string
-	entityCooccurance map[string][][]bool
+	entityCounts map[string]map[string]map[string]float32
+	intentCounts map[string]float32
+	utteranceCnt float32
+	// pairCounts counts the co-occurrences of entities of different types by intent.
+	pairCounts map[string]map[entityPair]float32
 }
 
 type Entity struct {
@@ -126,47 +272,55 @@ type Entity struct {
 	entVal  string
 }
 
-type Intent struct {
-	name         string
-	subUtterance []byte
+// entityPair is an unordered pair of entities, stored with the lesser entity first.
+type entityPair struct {
+	first  Entity
+	second Entity
 }
 
-type ResultRow struct {
-	Name       string
-	Count      int
-	Distrib    float32
-	Proportion float32
+func lessEntity(a Entity, b Entity) bool {
+	if a.entType != b.entType {
+		return a.entType < b.entType
+	}
+	return a.entVal < b.entVal
 }
 
-func (this *IntentEntityCounter) findEntities(subUtterance []byte) []Entity {
-	result := make([]Entity, 0)
-	for _, entity := range this.entityRe.FindAll(subUtterance, -1) {
-		trippedEntity := entity[1 : len(entity)-1]
-		splittedEntity := bytes.Split(trippedEntity, []byte(`](`))
-		result = append(result, Entity{string(splittedEntity[1]), string(splittedEntity[0])})
-	}
-	return result
+type Intent struct {
+	name     string
+	entities []Entity
+}
+
+// ResultRow is a row of the sample statistics. Name labels the row in the tables, while the exported
+// statistics have the intent, entity type and value the row counts in separate fields. The rows of entity
+// value pairs have the second entity of the pair in PairEntity and PairValue.
+type ResultRow struct {
+	Name       string  `json:"-"`
+	Intent     string  `json:"intent,omitempty"`
+	Entity     string  `json:"entity,omitempty"`
+	Value      string  `json:"value,omitempty"`
+	PairEntity string  `json:"pair_entity,omitempty"`
+	PairValue  string  `json:"pair_value,omitempty"`
+	Count      int     `json:"count"`
+	Distrib    float32 `json:"distribution"`
+	Proportion float32 `json:"avg_per_utterance"`
 }
 
 func (this *IntentEntityCounter) findIntents(utterance []byte) []Intent {
 	result := make([]Intent, 0)
-	intentPlaces := this.intentsRe.FindAllIndex(utterance, -1)
-	intentStart := 0
-	intentEnd := 0
-	for i, intentNameStartEnd := range intentPlaces {
-		if len(intentPlaces)-1 == i {
-			// last intent ends in the end of utterance
-			intentEnd = len(utterance)
-		} else {
-			// intent ends where next one starts
-			intentEnd = intentPlaces[i+1][0]
+	u, err := sal.Parse(string(utterance))
+	if err != nil {
+		log.Printf("Skipping invalid example: %s", err)
+		return result
+	}
+	for _, segment := range u.Segments {
+		if segment.Intent == "" {
+			continue
+		}
+		intent := Intent{name: segment.Intent}
+		for _, ent := range segment.Entities() {
+			intent.entities = append(intent.entities, Entity{ent.Type, ent.NormalizedValue()})
 		}
-		intentNameStart := intentNameStartEnd[0] + 1
-		intentNameEnd := intentNameStartEnd[1] - 1
-		intentName := string(utterance[intentNameStart:intentNameEnd])
-		subUtterance := utterance[intentStart:intentEnd]
-		intentStart = intentEnd
-		result = append(result, Intent{intentName, subUtterance})
+		result = append(result, intent)
 	}
 	return result
 }
@@ -175,7 +329,7 @@ func (this *IntentEntityCounter) findIntents(utterance []byte) []Intent {
 func (this *IntentEntityCounter) CountSingle(utterance []byte) {
 	for _, intent := range this.findIntents(utterance) {
 		this.intentCounts[intent.name] += 1
-		for _, ent := range this.findEntities(intent.subUtterance) {
+		for _, ent := range intent.entities {
 			if _, ok := this.entityCounts[intent.name]; !ok {
 				this.entityCounts[intent.name] = make(map[string]map[string]float32)
 			}
@@ -188,38 +342,49 @@ func (this *IntentEntityCounter) CountSingle(utterance []byte) {
 	}
 }
 
-// CountEntityPairs counts the co-occurance of multiple entities in each intent
+// CountEntityPairs counts the co-occurance of multiple entities in each intent. Each pair of entities of
+// different types is counted once per intent, however many times they occur in it.
 func (this *IntentEntityCounter) CountEntityPairs(utterance []byte) {
-	if this.entityToInt == nil {
-		entityToInt := make(map[string]int)
-		entityToType := make(map[string]string)
-		for _, entTypes := range this.entityCounts {
-			for entType, entValues := range entTypes {
-				for entVal := range entValues {
-					if _, ok := entityToInt[entVal]; !ok {
-						entityToInt[entVal] = len(entityToInt)
-					}
-					entityToType[entVal] = entType
-				}
-			}
-		}
-		this.entityToInt = entityToInt
-		this.entityToType = entityToType
-		this.entityCooccurance = make(map[string][][]bool)
+	if this.pairCounts == nil {
+		this.pairCounts = make(map[string]map[entityPair]float32)
 	}
 	for _, intent := range this.findIntents(utterance) {
-		occurences := make([]bool, len(this.entityToInt))
-		for _, ent := range this.findEntities(intent.subUtterance) {
-			occurences[this.entityToInt[ent.entVal]] = true
+		entities := make([]Entity, 0, len(intent.entities))
+		seen := make(map[Entity]bool, len(intent.entities))
+		for _, ent := range intent.entities {
+			if !seen[ent] {
+				seen[ent] = true
+				entities = append(entities, ent)
+			}
+		}
+		if len(entities) < 2 {
+			continue
+		}
+		pairs := this.pairCounts[intent.name]
+		if pairs == nil {
+			pairs = make(map[entityPair]float32)
+			this.pairCounts[intent.name] = pairs
+		}
+		for i, a := range entities {
+			for _, b := range entities[i+1:] {
+				if a.entType == b.entType {
+					continue
+				}
+				pair := entityPair{a, b}
+				if lessEntity(b, a) {
+					pair = entityPair{b, a}
+				}
+				pairs[pair] += 1
+			}
 		}
-		this.entityCooccurance[intent.name] = append(this.entityCooccurance[intent.name], occurences)
 	}
 }
 
 func normalize(rows []ResultRow, total float32) []ResultRow {
 	result := make([]ResultRow, 0)
 	for _, row := range rows {
-		result = append(result, ResultRow{row.Name, row.Count, (row.Distrib / total), row.Proportion})
+		row.Distrib /= total
+		result = append(result, row)
 	}
 	return result
 }
@@ -233,24 +398,12 @@ func sortByCount(rows []ResultRow) {
 	})
 }
 
-func (this *IntentEntityCounter) generatePairs() [][]string {
-	res := make([][]string, 0)
-	for k1, v1 := range this.entityToInt {
-		for k2, v2 := range this.entityToInt {
-			if v1 < v2 && this.entityToType[k1] != this.entityToType[k2] {
-				res = append(res, []string{k1, k2})
-			}
-		}
-	}
-	return res
-}
-
 func (this *IntentEntityCounter) GetIntentCounts() []ResultRow {
 	result := make([]ResultRow, 0)
 	var total float32
 	for intentName, intentCnt := range this.intentCounts {
 		total += intentCnt
-		row := ResultRow{intentName, int(intentCnt), intentCnt, (intentCnt / this.utteranceCnt)}
+		row := ResultRow{Name: intentName, Intent: intentName, Count: int(intentCnt), Distrib: intentCnt, Proportion: intentCnt / this.utteranceCnt}
 		result = append(result, row)
 	}
 	sortByCount(result)
@@ -270,7 +423,7 @@ func (this *IntentEntityCounter) GetEntityTypeCounts() []ResultRow {
 		}
 	}
 	for entityType, cnt := range entityTypeCounts {
-		row := ResultRow{entityType, int(cnt), cnt, (cnt / this.utteranceCnt)}
+		row := ResultRow{Name: entityType, Entity: entityType, Count: int(cnt), Distrib: cnt, Proportion: cnt / this.utteranceCnt}
 		result = append(result, row)
 	}
 	sortByCount(result)
@@ -279,18 +432,20 @@ func (this *IntentEntityCounter) GetEntityTypeCounts() []ResultRow {
 
 func (this *IntentEntityCounter) GetEntityValueCounts() []ResultRow {
 	result := make([]ResultRow, 0)
-	entityValueCounts := make(map[string]float32)
+	// the same value of different entity types is counted separately
+	entityValueCounts := make(map[Entity]float32)
 	var total float32
 	for _, entTypes := range this.entityCounts {
-		for _, entValues := range entTypes {
+		for entType, entValues := range entTypes {
 			for entVal, cnt := range entValues {
-				entityValueCounts[entVal] += cnt
+				entityValueCounts[Entity{entType, entVal}] += cnt
 				total += cnt
 			}
 		}
 	}
-	for entityVal, cnt := range entityValueCounts {
-		row := ResultRow{entityVal, int(cnt), cnt, (cnt / this.utteranceCnt)}
+	for entity, cnt := range entityValueCounts {
+		name := entity.entType + "=" + entity.entVal
+		row := ResultRow{Name: name, Entity: entity.entType, Value: entity.entVal, Count: int(cnt), Distrib: cnt, Proportion: cnt / this.utteranceCnt}
 		result = append(result, row)
 	}
 	sortByCount(result)
@@ -308,7 +463,7 @@ func (this *IntentEntityCounter) GetIntentEntityTypeCounts() []ResultRow {
 				total += cnt
 			}
 			name := intentName + "(" + entType + "=*)"
-			row := ResultRow{name, int(entTypeCnt), entTypeCnt, (entTypeCnt / this.utteranceCnt)}
+			row := ResultRow{Name: name, Intent: intentName, Entity: entType, Count: int(entTypeCnt), Distrib: entTypeCnt, Proportion: entTypeCnt / this.utteranceCnt}
 			result = append(result, row)
 		}
 	}
@@ -324,7 +479,7 @@ func (this *IntentEntityCounter) GetIntentEntityValueCounts() []ResultRow {
 			for entValue, cnt := range entValues {
 				total += cnt
 				name := intentName + "(" + entType + "=" + entValue + ")"
-				row := ResultRow{name, int(cnt), cnt, (cnt / this.utteranceCnt)}
+				row := ResultRow{Name: name, Intent: intentName, Entity: entType, Value: entValue, Count: int(cnt), Distrib: cnt, Proportion: cnt / this.utteranceCnt}
 				result = append(result, row)
 			}
 		}
@@ -336,21 +491,22 @@ func (this *IntentEntityCounter) GetIntentEntityValueCounts() []ResultRow {
 func (this *IntentEntityCounter) GetIntentEntityValuePairCounts() []ResultRow {
 	result := make([]ResultRow, 0)
 	var total float32
-	combinations := this.generatePairs()
-	for intentName, occurences := range this.entityCooccurance {
-		for _, comb := range combinations {
-			ind1 := this.entityToInt[comb[0]]
-			ind2 := this.entityToInt[comb[1]]
-			var cnt float32
-			for _, occurance := range occurences {
-				if occurance[ind1] && occurance[ind2] {
-					cnt += 1.0
-					total += 1.0
-				}
+	for intentName, pairs := range this.pairCounts {
+		for pair, cnt := range pairs {
+			total += cnt
+			name := intentName + "(" + pair.first.entType + "=" + pair.first.entVal
+			name += "," + pair.second.entType + "=" + pair.second.entVal + ")"
+			row := ResultRow{
+				Name:       name,
+				Intent:     intentName,
+				Entity:     pair.first.entType,
+				Value:      pair.first.entVal,
+				PairEntity: pair.second.entType,
+				PairValue:  pair.second.entVal,
+				Count:      int(cnt),
+				Distrib:    cnt,
+				Proportion: cnt / this.utteranceCnt,
 			}
-			name := intentName + "(" + this.entityToType[comb[0]] + "=" + comb[0]
-			name += "," + this.entityToType[comb[1]] + "=" + comb[1] + ")"
-			row := ResultRow{name, int(cnt), cnt, (cnt / this.utteranceCnt)}
 			result = append(result, row)
 		}
 	}
@@ -359,11 +515,9 @@ func (this *IntentEntityCounter) GetIntentEntityValuePairCounts() []ResultRow {
 }
 
 func CreateCounter(examples []string, advanced bool) IntentEntityCounter {
-	intentsRe := regexp.MustCompile(`\*(.*?) `)
-	entityRe := regexp.MustCompile(`\[(.*?)\]\((.*?)\)`)
 	entityCounts := make(map[string]map[string]map[string]float32)
 	intentCounts := make(map[string]float32)
-	counter := IntentEntityCounter{entityCounts, intentCounts, intentsRe, entityRe, 0.0, nil, nil, nil}
+	counter := IntentEntityCounter{entityCounts, intentCounts, 0.0, nil}
 	for _, example := range examples {
 		counter.CountSingle([]byte(example))
 	}
@@ -392,30 +546,119 @@ func printLines(out io.Writer, name string, rows []ResultRow, lineLimit int32) {
 	}
 }
 
-func printStats(out io.Writer, examples []string, normal bool, advanced bool, lineLimit int32) {
-	counter := CreateCounter(examples, advanced)
+// statsSection is a table of the sample statistics.
+type statsSection struct {
+	title string
+	rows  []ResultRow
+	// limited tells if the line limit of the advanced stats applies to the table.
+	limited bool
+}
 
-	log.Printf("There was %d utterances in the sample of %d examples \n", int32(counter.utteranceCnt), len(examples))
+func statsSections(counter *IntentEntityCounter, normal bool, advanced bool) []statsSection {
+	var sections []statsSection
 	if normal {
-		printLines(out, "INTENTS", counter.GetIntentCounts(), -1)
-		printLines(out, "ENTITY TYPES", counter.GetEntityTypeCounts(), -1)
-		printLines(out, "ENTITY VALUES", counter.GetEntityValueCounts(), -1)
+		sections = append(sections,
+			statsSection{"INTENTS", counter.GetIntentCounts(), false},
+			statsSection{"ENTITY TYPES", counter.GetEntityTypeCounts(), false},
+			statsSection{"ENTITY VALUES", counter.GetEntityValueCounts(), false},
+		)
 	}
 	if advanced {
-		printLines(out, "ENTITY TYPES PER INTENT", counter.GetIntentEntityTypeCounts(), lineLimit)
-		printLines(out, "ENTITY VALUES PER INTENT", counter.GetIntentEntityValueCounts(), lineLimit)
-		printLines(out, "ENTITY VALUE PAIRS PER INTENT", counter.GetIntentEntityValuePairCounts(), lineLimit)
+		sections = append(sections,
+			statsSection{"ENTITY TYPES PER INTENT", counter.GetIntentEntityTypeCounts(), true},
+			statsSection{"ENTITY VALUES PER INTENT", counter.GetIntentEntityValueCounts(), true},
+			statsSection{"ENTITY VALUE PAIRS PER INTENT", counter.GetIntentEntityValuePairCounts(), true},
+		)
+	}
+	return sections
+}
+
+// sampleStats is the JSON export of the sample statistics. The sections are omitted unless requested.
+type sampleStats struct {
+	Examples                  int         `json:"examples"`
+	Utterances                int         `json:"utterances"`
+	Intents                   []ResultRow `json:"intents,omitempty"`
+	EntityTypes               []ResultRow `json:"entity_types,omitempty"`
+	EntityValues              []ResultRow `json:"entity_values,omitempty"`
+	EntityTypesPerIntent      []ResultRow `json:"entity_types_per_intent,omitempty"`
+	EntityValuesPerIntent     []ResultRow `json:"entity_values_per_intent,omitempty"`
+	EntityValuePairsPerIntent []ResultRow `json:"entity_value_pairs_per_intent,omitempty"`
+}
+
+func printStats(out io.Writer, examples []string, normal bool, advanced bool, lineLimit int32, format string) error {
+	counter := CreateCounter(examples, advanced)
+	sections := statsSections(&counter, normal, advanced)
+
+	log.Printf("There was %d utterances in the sample of %d examples \n", int32(counter.utteranceCnt), len(examples))
+	switch format {
+	case "json":
+		stats := sampleStats{Examples: len(examples), Utterances: int(counter.utteranceCnt)}
+		fields := map[string]*[]ResultRow{
+			"INTENTS":                       &stats.Intents,
+			"ENTITY TYPES":                  &stats.EntityTypes,
+			"ENTITY VALUES":                 &stats.EntityValues,
+			"ENTITY TYPES PER INTENT":       &stats.EntityTypesPerIntent,
+			"ENTITY VALUES PER INTENT":      &stats.EntityValuesPerIntent,
+			"ENTITY VALUE PAIRS PER INTENT": &stats.EntityValuePairsPerIntent,
+		}
+		for _, s := range sections {
+			*fields[s.title] = s.rows
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	case "csv":
+		w := csv.NewWriter(out)
+		if err := w.Write([]string{"SECTION", "INTENT", "ENTITY", "VALUE", "PAIR ENTITY", "PAIR VALUE", "COUNT", "DISTRIBUTION", "AVG PER UTTERANCE"}); err != nil {
+			return err
+		}
+		for _, s := range sections {
+			for _, row := range s.rows {
+				if err := w.Write([]string{
+					s.title,
+					row.Intent,
+					row.Entity,
+					row.Value,
+					row.PairEntity,
+					row.PairValue,
+					strconv.Itoa(row.Count),
+					strconv.FormatFloat(float64(row.Distrib), 'f', -1, 32),
+					strconv.FormatFloat(float64(row.Proportion), 'f', -1, 32),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+	for _, s := range sections {
+		limit := int32(-1)
+		if s.limited {
+			limit = lineLimit
+		}
+		printLines(out, s.title, s.rows, limit)
 	}
+	return nil
 }
 
 func init() {
 	RootCmd.AddCommand(sampleCmd)
 	sampleCmd.Flags().StringP("app", "a", "", "Application to sample the files from. Can be given as the first positional argument.")
+	sampleCmd.Flags().Bool("dry-run", false, dryRunUsage)
 	sampleCmd.Flags().Int("batch-size", 100, "How many examples to return. Must be between 32 and 10000.")
 	sampleCmd.Flags().Int("seed", 0, "Random seed to use when initializing the sampler.")
+	sampleCmd.Flags().Int("total", 0, "Total number of examples to sample. Compiles as many batches of --batch-size examples as needed, with seeds derived from --seed.")
+	sampleCmd.Flags().Bool("keep-duplicates", false, "Keep duplicate examples across batches with --total.")
+	sampleCmd.Flags().Bool("lint", false, "Check the sample for class imbalance, low lexical diversity, near-duplicate and conflicting examples, over-long utterances and imported entity values that never appear.")
+	sampleCmd.Flags().Bool("lint-strict", false, "With --lint, exit with status 1 also if only warnings are found.")
+	sampleCmd.Flags().String("export-eval", "", "Write a training and a test set of the examples to the given directory, both annotated (train.txt, test.txt) and without annotations (train-input.txt, test-input.txt).")
+	sampleCmd.Flags().Float64("test-ratio", 0.2, "Share of the examples of each intent to put in the test set with --export-eval.")
+	sampleCmd.Flags().Int64("split-seed", 0, "Random seed to use when splitting the examples with --export-eval.")
 
 	sampleCmd.Flags().Bool("stats", false, "Print intent and entity distributions to the output.")
 	sampleCmd.Flags().Bool("advanced-stats", false, "Print entity type, value and value pair distributions to the output.")
 	sampleCmd.Flags().Int("advanced-stats-limit", 10, "Line limit for advanced_stats. The lines are ordered by count.")
+	sampleCmd.Flags().String("stats-format", "table", "Format of the statistics: table, json or csv. The json and csv formats contain all lines and imply both --stats and --advanced-stats unless either is given.")
 	sampleCmd.Flags().SortFlags = false
 }
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var sampleDiffCmd = &cobra.Command{
+	Use:   "diff <app_id> <dir_a> <dir_b>",
+	Short: "Compare the example distributions of two SAL configurations",
+	Long:  "Compiles both configuration directories with the same seed and batch size and compares the distributions of intents, entity types and entity values in the samples. Lists the ones whose share changed by more than --threshold, and the ones that appear in only one of the samples.",
+	Example: `speechly sample diff <app_id> ./config-before ./config-after
+speechly sample diff <app_id> ./config-before ./config-after --batch-size 5000 --threshold 0.01`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		appId := args[0]
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		if batchSize < 32 || batchSize > 10000 {
+			log.Fatal("Batch size must be between 32 and 10000")
+		}
+		seed, _ := cmd.Flags().GetInt("seed")
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+
+		counters := make([]IntentEntityCounter, 2)
+		for i, dir := range args[1:] {
+			compile, err := sampleCompiler(ctx, appId, dir, batchSize, false)
+			if err != nil {
+				log.Fatalf("Sampling %s failed: %s", dir, err)
+			}
+			compileResult, err := compile(seed)
+			if err != nil {
+				log.Fatalf("Sampling %s failed: %s", dir, err)
+			}
+			if len(compileResult.Messages) > 0 {
+				printLineErrors(compileResult.Messages)
+				os.Exit(1)
+			}
+			counters[i] = CreateCounter(compileResult.Templates, false)
+		}
+
+		diffs := []distributionDiff{
+			diffRows("INTENTS", counters[0].GetIntentCounts(), counters[1].GetIntentCounts()),
+			diffRows("ENTITY TYPES", counters[0].GetEntityTypeCounts(), counters[1].GetEntityTypeCounts()),
+			diffRows("ENTITY VALUES", counters[0].GetEntityValueCounts(), counters[1].GetEntityValueCounts()),
+		}
+		if err := printDistributionDiffs(cmd.OutOrStdout(), diffs, threshold, args[1], args[2]); err != nil {
+			log.Fatalf("Printing the differences failed: %s", err)
+		}
+	},
+}
+
+// shareChange is the change of a single intent, entity type or entity value between two samples.
+type shareChange struct {
+	name   string
+	a, b   ResultRow
+	change float64
+}
+
+// distributionDiff compares a section of the sample statistics of two samples.
+type distributionDiff struct {
+	title   string
+	changes []shareChange
+	// onlyA and onlyB are the names that appear in only one of the samples.
+	onlyA []string
+	onlyB []string
+}
+
+func diffRows(title string, a []ResultRow, b []ResultRow) distributionDiff {
+	d := distributionDiff{title: title}
+	rowsA := make(map[string]ResultRow, len(a))
+	for _, row := range a {
+		rowsA[row.Name] = row
+	}
+	rowsB := make(map[string]ResultRow, len(b))
+	for _, row := range b {
+		rowsB[row.Name] = row
+	}
+	for name := range unionKeys(rowsA, rowsB) {
+		ra, inA := rowsA[name]
+		rb, inB := rowsB[name]
+		switch {
+		case !inB:
+			d.onlyA = append(d.onlyA, name)
+		case !inA:
+			d.onlyB = append(d.onlyB, name)
+		}
+		d.changes = append(d.changes, shareChange{name, ra, rb, float64(rb.Distrib - ra.Distrib)})
+	}
+	sort.Slice(d.changes, func(i, j int) bool {
+		ci, cj := math.Abs(d.changes[i].change), math.Abs(d.changes[j].change)
+		if ci == cj {
+			return d.changes[i].name < d.changes[j].name
+		}
+		return ci > cj
+	})
+	sort.Strings(d.onlyA)
+	sort.Strings(d.onlyB)
+	return d
+}
+
+func printDistributionDiffs(out io.Writer, diffs []distributionDiff, threshold float64, dirA string, dirB string) error {
+	w := tabwriter.NewWriter(out, 0, 8, 1, '\t', 0)
+	changed := false
+	for _, d := range diffs {
+		var rows []shareChange
+		for _, c := range d.changes {
+			if math.Abs(c.change) > threshold {
+				rows = append(rows, c)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		changed = true
+		fmt.Fprintf(w, "\n%s\tA COUNT\tA SHARE\tB COUNT\tB SHARE\tCHANGE\n", d.title)
+		for _, c := range rows {
+			fmt.Fprintf(w, "%s\t%d\t%f\t%d\t%f\t%+f\n", c.name, c.a.Count, c.a.Distrib, c.b.Count, c.b.Distrib, c.change)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Fprintf(out, "\nNo shares changed by more than %g\n", threshold)
+	}
+
+	for _, d := range diffs {
+		section := strings.ToLower(d.title)
+		if len(d.onlyA) > 0 {
+			fmt.Fprintf(out, "\nDisappeared %s (only in %s):\n", section, dirA)
+			for _, name := range d.onlyA {
+				fmt.Fprintf(out, "└─ %s\n", name)
+			}
+		}
+		if len(d.onlyB) > 0 {
+			fmt.Fprintf(out, "\nAppeared %s (only in %s):\n", section, dirB)
+			for _, name := range d.onlyB {
+				fmt.Fprintf(out, "└─ %s\n", name)
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	sampleCmd.AddCommand(sampleDiffCmd)
+	sampleDiffCmd.Flags().Int("batch-size", 1000, "How many examples to sample from each configuration. Must be between 32 and 10000.")
+	sampleDiffCmd.Flags().Int("seed", 0, "Random seed to use when initializing the sampler.")
+	sampleDiffCmd.Flags().Float64("threshold", 0.05, "Report the intents and entities whose share of the sample changed by more than the given value.")
+}
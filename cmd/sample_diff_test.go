@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRows(t *testing.T) {
+	row := func(name string, count int, share float32) ResultRow {
+		return ResultRow{Name: name, Count: count, Distrib: share}
+	}
+	tests := []struct {
+		name     string
+		a        []ResultRow
+		b        []ResultRow
+		expected distributionDiff
+	}{
+		{
+			name: "changed, disappeared and appeared",
+			a:    []ResultRow{row("x", 2, 0.5), row("y", 2, 0.5)},
+			b:    []ResultRow{row("x", 1, 0.25), row("z", 3, 0.75)},
+			expected: distributionDiff{
+				title: "INTENTS",
+				changes: []shareChange{
+					{"z", ResultRow{}, row("z", 3, 0.75), 0.75},
+					{"y", row("y", 2, 0.5), ResultRow{}, -0.5},
+					{"x", row("x", 2, 0.5), row("x", 1, 0.25), -0.25},
+				},
+				onlyA: []string{"y"},
+				onlyB: []string{"z"},
+			},
+		},
+		{
+			name: "equal changes are sorted by name",
+			a:    []ResultRow{row("b", 1, 0.5), row("a", 1, 0.5)},
+			b:    []ResultRow{row("a", 3, 0.75), row("b", 1, 0.25)},
+			expected: distributionDiff{
+				title: "INTENTS",
+				changes: []shareChange{
+					{"a", row("a", 1, 0.5), row("a", 3, 0.75), 0.25},
+					{"b", row("b", 1, 0.5), row("b", 1, 0.25), -0.25},
+				},
+			},
+		},
+		{
+			name:     "unchanged",
+			a:        []ResultRow{row("a", 1, 1)},
+			b:        []ResultRow{row("a", 5, 1)},
+			expected: distributionDiff{title: "INTENTS", changes: []shareChange{{"a", row("a", 1, 1), row("a", 5, 1), 0}}},
+		},
+		{
+			name:     "empty",
+			expected: distributionDiff{title: "INTENTS"},
+		},
+	}
+	for _, test := range tests {
+		if d := diffRows("INTENTS", test.a, test.b); !reflect.DeepEqual(test.expected, d) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, d)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/speechly/cli/pkg/sal"
+)
+
+// StratifiedSplit splits the examples into a training and a test set so that each combination of intents
+// is split in the given ratio. Identical examples always end up in the same set. The order of the
+// examples is preserved in both sets.
+func StratifiedSplit(examples []string, testRatio float64, seed int64) ([]string, []string) {
+	strata := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, example := range examples {
+		if seen[example] {
+			continue
+		}
+		seen[example] = true
+		key := ""
+		if u, err := sal.Parse(example); err == nil {
+			key = strings.Join(u.Intents(), "+")
+		}
+		strata[key] = append(strata[key], example)
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	inTest := make(map[string]bool)
+	for _, key := range sortedKeys(strata) {
+		distinct := strata[key]
+		r.Shuffle(len(distinct), func(i, j int) { distinct[i], distinct[j] = distinct[j], distinct[i] })
+		n := int(math.Round(testRatio * float64(len(distinct))))
+		for _, example := range distinct[:n] {
+			inTest[example] = true
+		}
+	}
+
+	var train, test []string
+	for _, example := range examples {
+		if inTest[example] {
+			test = append(test, example)
+		} else {
+			train = append(train, example)
+		}
+	}
+	return train, test
+}
+
+// exportEvalSets writes the training and test sets to the directory, both annotated (for `evaluate nlu`)
+// and without annotations (for `annotate`).
+func exportEvalSets(dir string, examples []string, testRatio float64, seed int64) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	train, test := StratifiedSplit(examples, testRatio, seed)
+	for _, set := range []struct {
+		name     string
+		examples []string
+	}{{"train", train}, {"test", test}} {
+		inputs := make([]string, len(set.examples))
+		for i, example := range set.examples {
+			text, err := removeAnnotations(example)
+			if err != nil {
+				return fmt.Errorf("invalid example %q: %v", example, err)
+			}
+			inputs[i] = text
+		}
+		if err := writeLines(filepath.Join(dir, set.name+".txt"), set.examples); err != nil {
+			return err
+		}
+		if err := writeLines(filepath.Join(dir, set.name+"-input.txt"), inputs); err != nil {
+			return err
+		}
+	}
+	log.Printf("Wrote %d training and %d test examples to %s", len(train), len(test), dir)
+	return nil
+}
+
+func writeLines(fn string, lines []string) error {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(fn, []byte(b.String()), 0644)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	salv1 "github.com/speechly/api/go/speechly/sal/v1"
+)
+
+func TestPrintStatsExport(t *testing.T) {
+	examples := []string{
+		`*order [large](size) [latte|LATTE](coffee)`,
+		`*order [large](size) [latte|LATTE](coffee)`,
+	}
+
+	var out bytes.Buffer
+	if err := printStats(&out, examples, true, true, -1, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var stats sampleStats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Intents) != 1 || stats.Intents[0].Intent != "order" || stats.Intents[0].Count != 2 {
+		t.Errorf("Unexpected intents %+v", stats.Intents)
+	}
+	if len(stats.EntityTypesPerIntent) != 2 || stats.EntityTypesPerIntent[0].Intent != "order" || stats.EntityTypesPerIntent[0].Entity != "coffee" {
+		t.Errorf("Unexpected entity types per intent %+v", stats.EntityTypesPerIntent)
+	}
+	pair := ResultRow{Intent: "order", Entity: "coffee", Value: "LATTE", PairEntity: "size", PairValue: "large", Count: 2, Distrib: 1, Proportion: 1}
+	if len(stats.EntityValuePairsPerIntent) != 1 || !reflect.DeepEqual(pair, stats.EntityValuePairsPerIntent[0]) {
+		t.Errorf("Expected pair %+v, got %+v", pair, stats.EntityValuePairsPerIntent)
+	}
+	if bytes.Contains(out.Bytes(), []byte("order(")) {
+		t.Errorf("Expected no combined names in the export, got %s", out.String())
+	}
+
+	out.Reset()
+	if err := printStats(&out, examples, false, true, -1, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"SECTION", "INTENT", "ENTITY", "VALUE", "PAIR ENTITY", "PAIR VALUE", "COUNT", "DISTRIBUTION", "AVG PER UTTERANCE"},
+		{"ENTITY TYPES PER INTENT", "order", "coffee", "", "", "", "2", "0.5", "1"},
+		{"ENTITY TYPES PER INTENT", "order", "size", "", "", "", "2", "0.5", "1"},
+		{"ENTITY VALUES PER INTENT", "order", "coffee", "LATTE", "", "", "2", "0.5", "1"},
+		{"ENTITY VALUES PER INTENT", "order", "size", "large", "", "", "2", "0.5", "1"},
+		{"ENTITY VALUE PAIRS PER INTENT", "order", "coffee", "LATTE", "size", "large", "2", "1", "1"},
+	}
+	if !reflect.DeepEqual(expected, records) {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, records)
+	}
+}
+
+func TestSampleTotal(t *testing.T) {
+	batches := map[int][]string{
+		10: {"a", "b", "a"},
+		11: {"b", "c", "d"},
+		12: {"c", "d"},
+		13: {"e"},
+	}
+	tests := []struct {
+		name           string
+		total          int
+		keepDuplicates bool
+		expected       [][]string
+		seeds          []int
+	}{
+		{"duplicates are skipped", 4, false, [][]string{{"a", "b"}, {"c", "d"}}, []int{10, 11}},
+		{"duplicates are kept", 4, true, [][]string{{"a", "b", "a"}, {"b"}}, []int{10, 11}},
+		{"truncated to total", 2, false, [][]string{{"a", "b"}}, []int{10}},
+		{"stops when a batch has no new examples", 10, false, [][]string{{"a", "b"}, {"c", "d"}}, []int{10, 11, 12}},
+	}
+	for _, test := range tests {
+		var seeds []int
+		compile := func(seed int) (*salv1.CompileResult, error) {
+			seeds = append(seeds, seed)
+			return &salv1.CompileResult{Templates: batches[seed]}, nil
+		}
+		var emitted [][]string
+		messages, err := sampleTotal(compile, 10, test.total, test.keepDuplicates, func(batch []string) {
+			emitted = append(emitted, batch)
+		})
+		if err != nil || messages != nil {
+			t.Errorf("%s: unexpected messages %v and error %v", test.name, messages, err)
+		}
+		if !reflect.DeepEqual(test.expected, emitted) {
+			t.Errorf("%s: expected batches %q, got %q", test.name, test.expected, emitted)
+		}
+		if !reflect.DeepEqual(test.seeds, seeds) {
+			t.Errorf("%s: expected seeds %v, got %v", test.name, test.seeds, seeds)
+		}
+	}
+
+	failing := errors.New("compile failed")
+	compile := func(seed int) (*salv1.CompileResult, error) {
+		if seed > 0 {
+			return nil, failing
+		}
+		return &salv1.CompileResult{Templates: []string{"a"}}, nil
+	}
+	if _, err := sampleTotal(compile, 0, 10, false, func([]string) {}); err != failing {
+		t.Errorf("Expected the compile error, got %v", err)
+	}
+
+	invalid := []*salv1.LineReference{{}}
+	compile = func(seed int) (*salv1.CompileResult, error) {
+		return &salv1.CompileResult{Messages: invalid}, nil
+	}
+	emitted := false
+	if messages, err := sampleTotal(compile, 0, 10, false, func([]string) { emitted = true }); err != nil || !reflect.DeepEqual(invalid, messages) || emitted {
+		t.Errorf("Expected the compiler messages and no examples, got %v, %v and emitted %v", messages, err, emitted)
+	}
+}
@@ -1,9 +1,12 @@
 package cmd_test
 
 import (
-	"github.com/speechly/cli/cmd"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
+
+	"github.com/speechly/cli/cmd"
 )
 
 func checkResultRowSliceEqual(t *testing.T, a []cmd.ResultRow, b []cmd.ResultRow) {
@@ -91,3 +94,125 @@ func TestGetIntentEntityValueCounts(t *testing.T) {
 	}
 	checkResultRowSliceEqual(t, expected, counter.GetIntentEntityValueCounts())
 }
+
+func TestGetIntentEntityValuePairCounts(t *testing.T) {
+	examples := []string{
+		`*order [large](size) [coffee](coffee) with [cream](addition) and [cream](addition)`,
+		`*order [small](size) [latte](coffee)`,
+		`*pick [small](size) [small](cup)`,
+	}
+	counter := cmd.CreateCounter(examples, true)
+
+	names := []string{
+		"order(addition=cream,coffee=coffee)", "order(addition=cream,size=large)", "order(coffee=coffee,size=large)",
+		"order(coffee=latte,size=small)", "pick(cup=small,size=small)",
+	}
+	expected := make([]cmd.ResultRow, 0)
+	for _, name := range names {
+		expected = append(expected, cmd.ResultRow{Name: name, Count: 1, Distrib: 1.0 / 5.0, Proportion: 1.0 / 3.0})
+	}
+	checkResultRowSliceEqual(t, expected, counter.GetIntentEntityValuePairCounts())
+}
+
+func TestGetEntityValueCountsUsesNormalizedValues(t *testing.T) {
+	examples := []string{
+		`*order [two|2](count) [lattes|latte](coffee)`,
+		`*order [2](count) [latte](coffee)`,
+	}
+	counter := cmd.CreateCounter(examples, false)
+
+	expected := []cmd.ResultRow{
+		cmd.ResultRow{Name: "coffee=latte", Count: 2, Distrib: 2.0 / 4.0, Proportion: 2.0 / 2.0},
+		cmd.ResultRow{Name: "count=2", Count: 2, Distrib: 2.0 / 4.0, Proportion: 2.0 / 2.0},
+	}
+	checkResultRowSliceEqual(t, expected, counter.GetEntityValueCounts())
+}
+
+func TestGetEntityValueCountsSeparatesTypes(t *testing.T) {
+	examples := []string{
+		`*order a [small](size) coffee in a [small](cup)`,
+		`*order a [small](size) tea`,
+	}
+	counter := cmd.CreateCounter(examples, false)
+
+	expected := []cmd.ResultRow{
+		cmd.ResultRow{Name: "size=small", Count: 2, Distrib: 2.0 / 3.0, Proportion: 2.0 / 2.0},
+		cmd.ResultRow{Name: "cup=small", Count: 1, Distrib: 1.0 / 3.0, Proportion: 1.0 / 2.0},
+	}
+	rows := counter.GetEntityValueCounts()
+	checkResultRowSliceEqual(t, expected, rows)
+	for _, row := range rows {
+		if row.Entity+"="+row.Value != row.Name {
+			t.Errorf("Expected the entity type and value of %s, got %q and %q", row.Name, row.Entity, row.Value)
+		}
+	}
+}
+
+func benchmarkExamples(n int, values int) []string {
+	examples := make([]string, n)
+	for i := range examples {
+		examples[i] = fmt.Sprintf("*order a [size%d](size) [coffee%d](coffee) with [addition%d](addition) and [addition%d](addition)",
+			i%values, (i*7)%values, (i*13)%values, (i*17)%values)
+	}
+	return examples
+}
+
+func BenchmarkCreateCounter(b *testing.B) {
+	examples := benchmarkExamples(10000, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmd.CreateCounter(examples, true)
+	}
+}
+
+func BenchmarkGetIntentEntityValuePairCounts(b *testing.B) {
+	counter := cmd.CreateCounter(benchmarkExamples(10000, 5000), true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.GetIntentEntityValuePairCounts()
+	}
+}
+
+func TestStratifiedSplit(t *testing.T) {
+	var examples []string
+	for i := 0; i < 10; i++ {
+		examples = append(examples, fmt.Sprintf("*turn_on turn on the [lights %d](device)", i))
+	}
+	for i := 0; i < 5; i++ {
+		examples = append(examples, fmt.Sprintf("*turn_off turn off the [lights %d](device)", i))
+	}
+	examples = append(examples, examples[0], examples[10])
+
+	train, test := cmd.StratifiedSplit(examples, 0.2, 1)
+	if len(train)+len(test) != len(examples) {
+		t.Fatalf("Expected %d examples in total, got %d", len(examples), len(train)+len(test))
+	}
+	inTest := make(map[string]int)
+	counts := make(map[string]int)
+	for _, example := range test {
+		if inTest[example] == 0 {
+			counts[strings.Fields(example)[0]]++
+		}
+		inTest[example]++
+	}
+	inTrain := make(map[string]int)
+	for _, example := range train {
+		if inTest[example] > 0 {
+			t.Errorf("Example %s is in both sets", example)
+		}
+		inTrain[example]++
+	}
+	if counts["*turn_on"] != 2 || counts["*turn_off"] != 1 {
+		t.Errorf("Expected 2 distinct turn_on and 1 turn_off examples in the test set, got %v", counts)
+	}
+	for _, duplicate := range []string{examples[0], examples[10]} {
+		if inTest[duplicate]+inTrain[duplicate] != 2 || (inTest[duplicate] != 2 && inTrain[duplicate] != 2) {
+			t.Errorf("Expected both copies of %s in the same set, got %d in the test set and %d in the training set", duplicate, inTest[duplicate], inTrain[duplicate])
+		}
+	}
+
+	train2, test2 := cmd.StratifiedSplit(examples, 0.2, 1)
+	if strings.Join(train, "\n") != strings.Join(train2, "\n") || strings.Join(test, "\n") != strings.Join(test2, "\n") {
+		t.Errorf("Expected the same split with the same seed")
+	}
+}
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/speechly/cli/pkg/clients"
+	"github.com/speechly/cli/pkg/sal"
+)
+
+var sluEvalCmd = &cobra.Command{
+	Use:   "slu",
+	Short: "Evaluate the spoken language understanding accuracy of the given application model",
+	Long:  "Evaluates the full spoken pipeline from audio to intents and entities. The audio of the corpus is streamed through the Streaming API and the recognized intents and entities are scored against the ground truth, together with the word error rate of the transcripts.\n\nThe `transcript` fields of the corpus must be SAL annotated, e.g. `*turn_on turn on the [lights](device)`.",
+	Example: `speechly evaluate slu <app_id> annotated-ground-truths.jsonl
+speechly evaluate slu <app_id> annotated-ground-truths.jsonl --relax --min-intent-accuracy turn_on=0.9 --max-wer 0.1`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		appID := args[0]
+		matching, err := readNLUMatching(cmd)
+		if err != nil {
+			log.Fatalf("Reading matching flags failed: %v", err)
+		}
+
+		ac, err := readAudioCorpus(args[1])
+		if err != nil {
+			log.Fatalf("Reading corpus failed: %v", err)
+		}
+		groundTruth := make([]string, len(ac))
+		for i, aci := range ac {
+			if aci.Transcript == "" {
+				log.Fatalf("Missing ground truth for %s", aci.Audio)
+			}
+			if groundTruth[i], err = canonicalAnnotation(aci.Transcript); err != nil {
+				log.Fatalf("Invalid ground truth for %s: %v", aci.Audio, err)
+			}
+		}
+
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			log.Fatalf("Reading parallel flag failed: %v", err)
+		}
+		results, err := streamCorpus(ctx, appID, args[1], ac, parallel)
+		if err != nil {
+			log.Fatalf("Streaming failed: %v", err)
+		}
+
+		transcribed := make([]AudioCorpusItem, len(ac))
+		predictions := make([]string, len(ac))
+		predicted := make([][]annotatedSegment, len(ac))
+		for i, aci := range ac {
+			transcribed[i] = AudioCorpusItem{Audio: aci.Audio, Transcript: aci.Transcript, Hypothesis: results[i].transcript()}
+			predictions[i], predicted[i] = results[i].annotated()
+		}
+
+		asrReport := evaluateTranscripts(transcribed, asrScoring{annotated: true, entityWeight: 1})
+		report := evaluateAnnotatedUtterances(predictions, predicted, groundTruth, matching)
+		report.Type = "slu"
+		report.AppID = appID
+		report.WER = asrReport.WER
+		report.Errors = asrReport.Errors
+		report.Words = asrReport.Words
+		report.KeywordMetrics = asrReport.KeywordMetrics
+		finishEvaluation(cmd, report, args[1])
+	},
+}
+
+// canonicalAnnotation formats the SAL annotated line in canonical form without removable tokens, which
+// are never part of the results of the API.
+func canonicalAnnotation(line string) (string, error) {
+	u, err := sal.Parse(line)
+	if err != nil {
+		return "", err
+	}
+	return u.WithoutRemovables().String(), nil
+}
+
+// sluSegment collects the final results of a single segment of an SLU stream.
+type sluSegment struct {
+	words    map[int32]string
+	intent   string
+	entities []*sluv1.SLUEntity
+}
+
+// sluResult collects the final results of an SLU stream. Tentative results are ignored.
+type sluResult struct {
+	segments map[int32]*sluSegment
+}
+
+func newSLUResult() *sluResult {
+	return &sluResult{segments: make(map[int32]*sluSegment)}
+}
+
+func (r *sluResult) add(res *sluv1.SLUResponse) {
+	seg, ok := r.segments[res.GetSegmentId()]
+	if !ok {
+		seg = &sluSegment{words: make(map[int32]string)}
+	}
+	switch v := res.StreamingResponse.(type) {
+	case *sluv1.SLUResponse_Transcript:
+		seg.words[v.Transcript.GetIndex()] = v.Transcript.GetWord()
+	case *sluv1.SLUResponse_Intent:
+		seg.intent = v.Intent.GetIntent()
+	case *sluv1.SLUResponse_Entity:
+		seg.entities = append(seg.entities, v.Entity)
+	default:
+		return
+	}
+	r.segments[res.GetSegmentId()] = seg
+}
+
+func (r *sluResult) sortedSegments() []*sluSegment {
+	ids := make([]int32, 0, len(r.segments))
+	for id := range r.segments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	segments := make([]*sluSegment, len(ids))
+	for i, id := range ids {
+		segments[i] = r.segments[id]
+	}
+	return segments
+}
+
+func (s *sluSegment) sortedWords() []string {
+	indices := make([]int32, 0, len(s.words))
+	for idx := range s.words {
+		indices = append(indices, idx)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	words := make([]string, len(indices))
+	for i, idx := range indices {
+		words[i] = s.words[idx]
+	}
+	return words
+}
+
+// transcript returns the words of all segments in order.
+func (r *sluResult) transcript() string {
+	var words []string
+	for _, seg := range r.sortedSegments() {
+		words = append(words, seg.sortedWords()...)
+	}
+	return strings.Join(words, " ")
+}
+
+// annotated returns the results in SAL notation and as segments for computing the NLU metrics.
+func (r *sluResult) annotated() (string, []annotatedSegment) {
+	var u sal.Utterance
+	var segments []annotatedSegment
+	for _, seg := range r.sortedSegments() {
+		starts := make(map[int32]*sluv1.SLUEntity)
+		for _, ent := range seg.entities {
+			if ent.GetEndPosition() > ent.GetStartPosition() {
+				starts[ent.GetStartPosition()] = ent
+			}
+		}
+		s := sal.Segment{Intent: seg.intent}
+		as := annotatedSegment{intent: seg.intent}
+		for idx := int32(0); idx <= maxIndex(seg.words); idx++ {
+			ent, ok := starts[idx]
+			if !ok {
+				if w, ok := seg.words[idx]; ok {
+					s.Nodes = append(s.Nodes, sal.Node{Kind: sal.WordNode, Text: w})
+				}
+				continue
+			}
+			var words []string
+			for ; idx < ent.GetEndPosition(); idx++ {
+				if w, ok := seg.words[idx]; ok {
+					words = append(words, w)
+				}
+			}
+			idx--
+			n := sal.Node{Kind: sal.EntityNode, Text: strings.Join(words, " "), Type: ent.GetEntity()}
+			if ent.GetValue() != n.Text {
+				n.Value = ent.GetValue()
+			}
+			s.Nodes = append(s.Nodes, n)
+			as.entities = append(as.entities, annotatedEntity{entityType: n.Type, text: n.Text, value: n.NormalizedValue()})
+		}
+		u.Segments = append(u.Segments, s)
+		segments = append(segments, as)
+	}
+	return u.String(), segments
+}
+
+func maxIndex(words map[int32]string) int32 {
+	m := int32(-1)
+	for idx := range words {
+		if idx > m {
+			m = idx
+		}
+	}
+	return m
+}
+
+// streamCorpus sends the audio of each corpus item through its own SLU stream, with up to parallelism
+// streams at a time over a shared connection. The results are in the order of the corpus. On error, the
+// results of the items that were not completed are nil.
+func streamCorpus(ctx context.Context, appID string, corpusPath string, ac []AudioCorpusItem, parallelism int) ([]*sluResult, error) {
+	if parallelism < 1 {
+		return nil, fmt.Errorf("parallelism must be positive")
+	}
+	client, err := clients.SLUClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return streamCorpusWith(ctx, ac, parallelism, func(ctx context.Context, item AudioCorpusItem, onResponse func(*sluv1.SLUResponse)) error {
+		return streamAudio(ctx, client, appID, corpusAudioPath(corpusPath, item), nil, onResponse)
+	})
+}
+
+// corpusStreamer streams the audio of a corpus item, passing each response to onResponse.
+type corpusStreamer func(ctx context.Context, item AudioCorpusItem, onResponse func(*sluv1.SLUResponse)) error
+
+// streamCorpusWith streams the corpus items with stream, parallelism items at a time. The results are in the
+// order of the corpus. On error, the remaining items are cancelled and the first error is returned.
+func streamCorpusWith(ctx context.Context, ac []AudioCorpusItem, parallelism int, stream corpusStreamer) ([]*sluResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*sluResult, len(ac))
+	items := make(chan int)
+	bar := getBar("Transcribing", "utt", len(ac))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				res := newSLUResult()
+				if err := stream(ctx, ac[i], res.add); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("%s: %v", ac[i].Audio, err)
+						cancel()
+					})
+					continue
+				}
+				results[i] = res
+				_ = bar.Add(1)
+			}
+		}()
+	}
+
+	for i := range ac {
+		select {
+		case items <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	if firstErr != nil {
+		barClearOnError(bar)
+		return results, firstErr
+	}
+	if err := bar.Close(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+func init() {
+	evaluateCmd.AddCommand(sluEvalCmd)
+	sluEvalCmd.Flags().Int("parallel", 4, "Number of concurrent streams.")
+	addNLUMatchingFlags(sluEvalCmd)
+	sluEvalCmd.Flags().Float64("min-accuracy", 0, "Fail if the accuracy is below the given value.")
+	sluEvalCmd.Flags().StringToString("min-intent-accuracy", nil, "Fail if the accuracy of utterances with the given intent is below the value, e.g. turn_on=0.9.")
+	sluEvalCmd.Flags().StringToString("min-entity-accuracy", nil, "Fail if the accuracy of utterances with the given entity type is below the value, e.g. device=0.9.")
+	sluEvalCmd.Flags().Float64("max-wer", 0, "Fail if the word error rate is above the given value.")
+	addQualityGateFlags(sluEvalCmd)
+	addHistoryFlags(sluEvalCmd)
+}
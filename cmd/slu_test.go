@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+)
+
+func sluWord(segment int32, index int32, word string) *sluv1.SLUResponse {
+	return &sluv1.SLUResponse{SegmentId: segment, StreamingResponse: &sluv1.SLUResponse_Transcript{Transcript: &sluv1.SLUTranscript{Word: word, Index: index}}}
+}
+
+func sluIntent(segment int32, intent string) *sluv1.SLUResponse {
+	return &sluv1.SLUResponse{SegmentId: segment, StreamingResponse: &sluv1.SLUResponse_Intent{Intent: &sluv1.SLUIntent{Intent: intent}}}
+}
+
+func sluEntity(segment int32, entity string, value string, start int32, end int32) *sluv1.SLUResponse {
+	return &sluv1.SLUResponse{SegmentId: segment, StreamingResponse: &sluv1.SLUResponse_Entity{Entity: &sluv1.SLUEntity{Entity: entity, Value: value, StartPosition: start, EndPosition: end}}}
+}
+
+func TestSLUResultAnnotated(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*sluv1.SLUResponse
+		expected  string
+		segments  []annotatedSegment
+	}{
+		{
+			name: "entities with and without values",
+			responses: []*sluv1.SLUResponse{
+				sluWord(0, 0, "turn"), sluWord(0, 1, "on"), sluWord(0, 2, "the"), sluWord(0, 3, "kitchen"), sluWord(0, 4, "lights"),
+				sluIntent(0, "turn_on"),
+				sluEntity(0, "room", "kitchen", 3, 4),
+				sluEntity(0, "device", "LIGHTS", 4, 5),
+			},
+			expected: "*turn_on turn on the [kitchen](room) [lights|LIGHTS](device)",
+			segments: []annotatedSegment{{intent: "turn_on", entities: []annotatedEntity{{"room", "kitchen", "kitchen"}, {"device", "lights", "LIGHTS"}}}},
+		},
+		{
+			name: "segments and words out of order",
+			responses: []*sluv1.SLUResponse{
+				sluWord(1, 1, "off"), sluWord(1, 0, "turn"), sluIntent(1, "turn_off"),
+				sluWord(0, 0, "hello"),
+				{SegmentId: 0, StreamingResponse: &sluv1.SLUResponse_TentativeIntent{TentativeIntent: &sluv1.SLUIntent{Intent: "greet"}}},
+			},
+			expected: "hello *turn_off turn off",
+			segments: []annotatedSegment{{}, {intent: "turn_off"}},
+		},
+		{
+			name: "multi-word entity with a missing word and an empty entity",
+			responses: []*sluv1.SLUResponse{
+				sluWord(0, 0, "to"), sluWord(0, 1, "new"), sluWord(0, 3, "city"),
+				sluIntent(0, "travel"),
+				sluEntity(0, "city", "NEW YORK CITY", 1, 4),
+				sluEntity(0, "date", "", 0, 0),
+			},
+			expected: "*travel to [new city|NEW YORK CITY](city)",
+			segments: []annotatedSegment{{intent: "travel", entities: []annotatedEntity{{"city", "new city", "NEW YORK CITY"}}}},
+		},
+		{
+			name:     "empty",
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		r := newSLUResult()
+		for _, res := range test.responses {
+			r.add(res)
+		}
+		annotated, segments := r.annotated()
+		if annotated != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, annotated)
+		}
+		if !reflect.DeepEqual(test.segments, segments) {
+			t.Errorf("%s: expected segments %+v, got %+v", test.name, test.segments, segments)
+		}
+	}
+}
+
+func TestCanonicalAnnotation(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"*turn_on  turn (uh) on the [ lights ](device)", "*turn_on turn on the [lights](device)"},
+		{"(um) *turn_off [tv|TV](device) (noise)", "*turn_off [tv|TV](device)"},
+		{"hello there", "hello there"},
+	}
+	for _, test := range tests {
+		result, err := canonicalAnnotation(test.line)
+		if err != nil {
+			t.Errorf("%q: %v", test.line, err)
+		} else if result != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, result)
+		}
+	}
+	if _, err := canonicalAnnotation("*turn_on [lights(device)"); err == nil {
+		t.Error("Expected an error for an invalid annotation")
+	}
+}
+
+func corpusItems(n int) []AudioCorpusItem {
+	ac := make([]AudioCorpusItem, n)
+	for i := range ac {
+		ac[i] = AudioCorpusItem{Audio: fmt.Sprintf("a%d.wav", i)}
+	}
+	return ac
+}
+
+func TestStreamCorpusOrder(t *testing.T) {
+	ac := corpusItems(8)
+	results, err := streamCorpusWith(context.Background(), ac, 3, func(ctx context.Context, item AudioCorpusItem, onResponse func(*sluv1.SLUResponse)) error {
+		// the later items finish first
+		var i int
+		fmt.Sscanf(item.Audio, "a%d.wav", &i)
+		time.Sleep(time.Duration(8-i) * time.Millisecond)
+		onResponse(sluWord(0, 0, item.Audio))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if annotated, _ := res.annotated(); annotated != ac[i].Audio {
+			t.Errorf("Expected the result of %s at %d, got %q", ac[i].Audio, i, annotated)
+		}
+	}
+}
+
+func TestStreamCorpusFirstError(t *testing.T) {
+	ac := corpusItems(8)
+	results, err := streamCorpusWith(context.Background(), ac, 2, func(ctx context.Context, item AudioCorpusItem, onResponse func(*sluv1.SLUResponse)) error {
+		switch item.Audio {
+		case "a0.wav":
+			onResponse(sluWord(0, 0, "ok"))
+			return nil
+		case "a1.wav":
+			time.Sleep(10 * time.Millisecond)
+			return errors.New("stream failed")
+		}
+		// the other items only finish when cancelled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			onResponse(sluWord(0, 0, "not cancelled"))
+			return nil
+		}
+	})
+	if err == nil || err.Error() != "a1.wav: stream failed" {
+		t.Errorf("Expected the error of a1.wav, got %v", err)
+	}
+	if len(results) != len(ac) || results[0] == nil {
+		t.Fatalf("Expected the result of a0.wav, got %v", results)
+	}
+	for i, res := range results[1:] {
+		if res != nil {
+			annotated, _ := res.annotated()
+			t.Errorf("Expected no result for %s, got %q", ac[i+1].Audio, annotated)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-audio/audio"
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const streamSampleRate = 16000
+
+// audioPace controls how fast audio is sent to a stream. The audio is sent in chunks of chunkSamples
+// samples at speed times real time; zero speed sends the audio as fast as possible. The times when the
+// first audio chunk and the stop message were sent are recorded.
+type audioPace struct {
+	speed        float64
+	chunkSamples int
+
+	audioStart time.Time
+	stopSent   time.Time
+	samples    int
+}
+
+// audioDuration returns the duration of the audio sent so far.
+func (p *audioPace) audioDuration() time.Duration {
+	return time.Duration(p.samples) * time.Second / streamSampleRate
+}
+
+// send sends the chunk after waiting until it is due.
+func (p *audioPace) send(stream sluv1.SLU_StreamClient, chunk []int) error {
+	if p.audioStart.IsZero() {
+		p.audioStart = time.Now()
+	}
+	if p.speed > 0 {
+		due := p.audioStart.Add(time.Duration(float64(p.audioDuration()) / p.speed))
+		if wait := time.Until(due); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	data, err := linear16(chunk)
+	if err != nil {
+		return err
+	}
+	p.samples += len(chunk)
+	return stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Audio{Audio: data}})
+}
+
+// corpusAudioPath resolves the audio file of a corpus item relative to the corpus file.
+func corpusAudioPath(corpusPath string, aci AudioCorpusItem) string {
+	if corpusPath == aci.Audio {
+		return corpusPath
+	}
+	return path.Join(path.Dir(corpusPath), aci.Audio)
+}
+
+// streamAudio sends the audio file through a single SLU stream and calls onResponse for every response.
+// It returns when the server has closed the stream. If pace is nil, the audio is sent as fast as
+// possible in the chunks it is read in.
+func streamAudio(ctx context.Context, client sluv1.SLUClient, appID string, audioFilePath string, pace *audioPace, onResponse func(*sluv1.SLUResponse)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Stream(ctx)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				done <- nil
+				return
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+			if f, ok := res.StreamingResponse.(*sluv1.SLUResponse_Finished); ok && f.Finished.GetError() != nil {
+				done <- finishedError(f.Finished.GetError())
+				return
+			}
+			onResponse(res)
+		}
+	}()
+
+	if err := sendAudio(stream, appID, audioFilePath, pace); err != nil {
+		cancel()
+		<-done
+		return err
+	}
+	return <-done
+}
+
+// finishedError returns the error the server closed the stream with as a gRPC status error, so that
+// status.Code reports the code sent by the server. The code is either a name such as "INVALID_ARGUMENT"
+// or a number.
+func finishedError(e *sluv1.SLUError) error {
+	name := strings.ToUpper(e.Code)
+	var c codes.Code
+	if c.UnmarshalJSON([]byte(name)) != nil && c.UnmarshalJSON([]byte(strconv.Quote(name))) != nil {
+		return status.Error(codes.Unknown, fmt.Sprintf("%s: %s", e.Code, e.Message))
+	}
+	return status.Error(c, e.Message)
+}
+
+func sendAudio(stream sluv1.SLU_StreamClient, appID string, audioFilePath string, pace *audioPace) error {
+	err := stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Config{
+		Config: &sluv1.SLUConfig{
+			Encoding:        sluv1.SLUConfig_LINEAR16,
+			Channels:        1,
+			SampleRateHertz: streamSampleRate,
+			LanguageCode:    "en-US",
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Start{Start: &sluv1.SLUStart{
+		AppId: appID,
+	}}})
+	if err != nil {
+		return err
+	}
+
+	var pending []int
+	err = readAudio(audioFilePath, AudioCorpusItem{}, func(buffer audio.IntBuffer, n int) error {
+		if pace == nil {
+			data, err := linear16(buffer.Data[:n])
+			if err != nil {
+				return err
+			}
+			return stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Audio{Audio: data}})
+		}
+		pending = append(pending, buffer.Data[:n]...)
+		for len(pending) >= pace.chunkSamples {
+			if err := pace.send(stream, pending[:pace.chunkSamples]); err != nil {
+				return err
+			}
+			pending = pending[pace.chunkSamples:]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if pace != nil && len(pending) > 0 {
+		if err := pace.send(stream, pending); err != nil {
+			return err
+		}
+	}
+
+	err = stream.Send(&sluv1.SLURequest{StreamingRequest: &sluv1.SLURequest_Stop{Stop: &sluv1.SLUStop{}}})
+	if err != nil {
+		return err
+	}
+	if pace != nil {
+		pace.stopSent = time.Now()
+	}
+	return stream.CloseSend()
+}
+
+// linear16 encodes the samples as 16 bit little endian PCM.
+func linear16(samples []int) ([]byte, error) {
+	buffer16 := make([]uint16, len(samples))
+	for i, x := range samples {
+		buffer16[i] = uint16(x)
+	}
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.LittleEndian, buffer16); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	sluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFinishedError(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected codes.Code
+		message  string
+	}{
+		{"INVALID_ARGUMENT", codes.InvalidArgument, "bad audio"},
+		{"unavailable", codes.Unavailable, "bad audio"},
+		{"8", codes.ResourceExhausted, "bad audio"},
+		{"AUDIO_TOO_LONG", codes.Unknown, "AUDIO_TOO_LONG: bad audio"},
+	}
+	for _, test := range tests {
+		err := finishedError(&sluv1.SLUError{Code: test.code, Message: "bad audio"})
+		s, _ := status.FromError(err)
+		if s.Code() != test.expected || s.Message() != test.message {
+			t.Errorf("%s: expected %v %q, got %v %q", test.code, test.expected, test.message, s.Code(), s.Message())
+		}
+	}
+}
@@ -44,11 +44,15 @@ speechly transcribe files.jsonl --model /path/to/model/bundle`,
 		if err != nil {
 			log.Fatalf("Reading streaming flag failed: %v", err)
 		}
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			log.Fatalf("Reading parallel flag failed: %v", err)
+		}
 
 		var results []AudioCorpusItem
 
 		if useStreaming {
-			results, err = transcribeWithStreamingAPI(ctx, appID, inputPath, false)
+			results, err = transcribeWithStreamingAPI(ctx, appID, inputPath, false, parallel)
 		} else {
 			results, err = transcribeWithBatchAPI(ctx, appID, inputPath, false)
 		}
@@ -78,6 +82,7 @@ func init() {
 	transcribeCmd.Flags().StringP("app", "a", "", "Application ID to use for cloud transcription")
 	transcribeCmd.Flags().StringP("model", "m", "", "Model bundle file. This feature is available on Enterprise plans (https://speechly.com/pricing)")
 	transcribeCmd.Flags().Bool("streaming", false, "Use the Streaming API instead of the Batch API.")
+	transcribeCmd.Flags().Int("parallel", 4, "Number of concurrent streams when using the Streaming API.")
 	RootCmd.AddCommand(transcribeCmd)
 }
 
@@ -85,6 +90,9 @@ type AudioCorpusItem struct {
 	Audio      string `json:"audio"`
 	Hypothesis string `json:"hypothesis,omitempty"`
 	Transcript string `json:"transcript,omitempty"`
+	// Transcripts are additional acceptable ground truth transcripts. The hypothesis is scored against
+	// the reference with the fewest errors.
+	Transcripts []string `json:"transcripts,omitempty"`
 }
 
 type AudioCorpusItemBatch struct {
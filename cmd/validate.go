@@ -30,7 +30,8 @@ func (u ValidateWriter) Write(data []byte) (n int, err error) {
 var validateCmd = &cobra.Command{
 	Use: "validate",
 	Example: `speechly validate <app_id> .
-speechly validate --app <app_id> /path/to/config`,
+speechly validate --app <app_id> /path/to/config
+speechly validate --dry-run <app_id> /path/to/config`,
 	Short: "Validate the given configuration for syntax errors",
 	Long:  "The contents of the directory given as argument is sent to the API and validated. Possible errors are printed to stdout.",
 	Args:  cobra.RangeArgs(1, 2),
@@ -53,14 +54,20 @@ speechly validate --app <app_id> /path/to/config`,
 		}
 		absPath, _ := filepath.Abs(inDir)
 		log.Printf("Project dir: %s\n", absPath)
-		// create a tar package from files in memory
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if err := printUploadFiles(cmd.OutOrStdout(), inDir); err != nil {
+				log.Fatalf("Listing files failed: %s", err)
+			}
+			return
+		}
+		// list the files to package
 		uploadData := upload.CreateTarFromDir(inDir)
 
 		if len(uploadData.Files) == 0 {
 			log.Fatalf("No files found for validation!\n\nPlease ensure the files are named *.yaml or *.csv")
 		}
 
-		messages, err := validateUploadData(ctx, appId, uploadData)
+		messages, _, err := validateUploadData(ctx, appId, uploadData)
 		if err != nil {
 			log.Fatalf("Validate failed: %s", err)
 		} else if len(messages) > 0 {
@@ -70,33 +77,34 @@ speechly validate --app <app_id> /path/to/config`,
 	},
 }
 
-func validateUploadData(ctx context.Context, appId string, ud upload.UploadData) ([]*salv1.LineReference, error) {
+func validateUploadData(ctx context.Context, appId string, ud upload.UploadData) ([]*salv1.LineReference, string, error) {
 	compileClient, err := clients.CompileClient(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// open a stream for upload
 	stream, err := compileClient.Validate(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// flush the tar from memory to the stream
+	// stream the tar package of the files to the API
 	validateWriter := ValidateWriter{appId, stream}
-	_, err = ud.Buf.WriteTo(validateWriter)
+	_, checksum, err := streamUploadData(ud, validateWriter)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	validateResult, err := stream.CloseAndRecv()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return validateResult.Messages, nil
+	return validateResult.Messages, checksum, nil
 }
 
 func init() {
 	RootCmd.AddCommand(validateCmd)
 	validateCmd.Flags().StringP("app", "a", "", "Application to validate the files for. Can be given as the first positional argument.")
+	validateCmd.Flags().Bool("dry-run", false, dryRunUsage)
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"math"
 	"strings"
 
@@ -56,3 +57,151 @@ func runifyWord(word string, w2r map[string]rune) rune {
 	}
 	return val
 }
+
+type alignOp int
+
+const (
+	opMatch alignOp = iota
+	opSubstitute
+	opInsert
+	opDelete
+)
+
+// alignedWord is a single step of a word alignment. For insertions ref is empty and for deletions hyp is
+// empty.
+type alignedWord struct {
+	op  alignOp
+	ref string
+	hyp string
+}
+
+// alignWords computes a minimum edit distance alignment between the reference and hypothesis words.
+// Words are compared case-insensitively.
+func alignWords(ref []string, hyp []string) []alignedWord {
+	c := cases.Upper(language.English)
+	r := make([]string, len(ref))
+	for i, w := range ref {
+		r[i] = c.String(w)
+	}
+	h := make([]string, len(hyp))
+	for i, w := range hyp {
+		h[i] = c.String(w)
+	}
+
+	d := make([][]int, len(r)+1)
+	for i := range d {
+		d[i] = make([]int, len(h)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(r); i++ {
+		for j := 1; j <= len(h); j++ {
+			cost := 1
+			if r[i-1] == h[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j-1]+cost, d[i-1][j]+1, d[i][j-1]+1)
+		}
+	}
+
+	var result []alignedWord
+	i, j := len(r), len(h)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && r[i-1] == h[j-1] && d[i][j] == d[i-1][j-1]:
+			result = append(result, alignedWord{opMatch, ref[i-1], hyp[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && d[i][j] == d[i-1][j-1]+1:
+			result = append(result, alignedWord{opSubstitute, ref[i-1], hyp[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && d[i][j] == d[i-1][j]+1:
+			result = append(result, alignedWord{opDelete, ref[i-1], ""})
+			i--
+		default:
+			result = append(result, alignedWord{opInsert, "", hyp[j-1]})
+			j--
+		}
+	}
+	for a, b := 0, len(result)-1; a < b; a, b = a+1, b-1 {
+		result[a], result[b] = result[b], result[a]
+	}
+	return result
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// maxAlternatives limits the number of references an alternation can expand to.
+const maxAlternatives = 1000
+
+func (aci AudioCorpusItem) hasGroundTruth() bool {
+	return aci.Transcript != "" || len(aci.Transcripts) > 0
+}
+
+// references returns the ground truth transcripts of the item, with the alternations expanded.
+func (aci AudioCorpusItem) references() ([]string, error) {
+	var refs []string
+	for _, t := range append([]string{aci.Transcript}, aci.Transcripts...) {
+		if t == "" {
+			continue
+		}
+		expanded, err := expandAlternatives(t)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, expanded...)
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("missing ground truth")
+	}
+	return refs, nil
+}
+
+// expandAlternatives expands the alternations of a transcript into all the transcripts it accepts. An
+// alternation lists the acceptable variants in braces, e.g. "{ok|okay} send an {e-mail|email}". An empty
+// variant makes the alternation optional, e.g. "{uh|}".
+func expandAlternatives(s string) ([]string, error) {
+	results := []string{""}
+	for len(s) > 0 {
+		open := strings.IndexAny(s, "{}")
+		if open < 0 {
+			for i := range results {
+				results[i] += s
+			}
+			break
+		}
+		if s[open] == '}' {
+			return nil, fmt.Errorf("unexpected '}' in %q", s)
+		}
+		end := strings.IndexAny(s[open+1:], "{}")
+		if end < 0 || s[open+1+end] != '}' {
+			return nil, fmt.Errorf("unterminated or nested alternation in %q", s)
+		}
+		end += open + 1
+		variants := strings.Split(s[open+1:end], "|")
+		if len(results)*len(variants) > maxAlternatives {
+			return nil, fmt.Errorf("alternations expand to more than %d transcripts", maxAlternatives)
+		}
+		expanded := make([]string, 0, len(results)*len(variants))
+		for _, r := range results {
+			for _, v := range variants {
+				expanded = append(expanded, r+s[:open]+v)
+			}
+		}
+		results = expanded
+		s = s[end+1:]
+	}
+	for i, r := range results {
+		results[i] = strings.Join(strings.Fields(r), " ")
+	}
+	return results, nil
+}
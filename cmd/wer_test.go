@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandAlternatives(t *testing.T) {
+	tests := []struct {
+		transcript string
+		expected   []string
+		err        bool
+	}{
+		{transcript: "turn on the lights", expected: []string{"turn on the lights"}},
+		{transcript: "{ok|okay} send an {e-mail|email}", expected: []string{"ok send an e-mail", "ok send an email", "okay send an e-mail", "okay send an email"}},
+		{transcript: "{uh|} turn  on", expected: []string{"uh turn on", "turn on"}},
+		{transcript: "turn {} on", expected: []string{"turn on"}},
+		{transcript: "{a|b}{c|d}", expected: []string{"ac", "ad", "bc", "bd"}},
+		{transcript: "", expected: []string{""}},
+		{transcript: "{a|{b|c}}", err: true},
+		{transcript: "{a|b", err: true},
+		{transcript: "a|b}", err: true},
+		{transcript: "}{a}", err: true},
+		{transcript: strings.Repeat("{a|b|c|d}", 5), err: true},
+	}
+	for _, test := range tests {
+		result, err := expandAlternatives(test.transcript)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", test.transcript, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.transcript, err)
+		} else if !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%q: expected %q, got %q", test.transcript, test.expected, result)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     AudioCorpusItem
+		expected []string
+		err      bool
+	}{
+		{name: "transcript", item: AudioCorpusItem{Transcript: "turn on"}, expected: []string{"turn on"}},
+		{name: "transcripts", item: AudioCorpusItem{Transcript: "{ok|okay}", Transcripts: []string{"all right", ""}}, expected: []string{"ok", "okay", "all right"}},
+		{name: "only transcripts", item: AudioCorpusItem{Transcripts: []string{"yes", "{yeah|yep}"}}, expected: []string{"yes", "yeah", "yep"}},
+		{name: "missing", item: AudioCorpusItem{Audio: "a.wav"}, err: true},
+		{name: "unbalanced", item: AudioCorpusItem{Transcript: "ok", Transcripts: []string{"{yes"}}, err: true},
+	}
+	for _, test := range tests {
+		result, err := test.item.references()
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.name, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(test.expected, result) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	wluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wluRetryDelay is the delay before the first retry of a failed request, growing linearly with each retry.
+var wluRetryDelay = time.Second
+
+// WLUOptions control how the requests are split into WLU Texts calls.
+type WLUOptions struct {
+	BatchSize   int
+	Concurrency int
+	Retries     int
+}
+
+func addWLUFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("wlu-batch-size", 500, "How many utterances to send to the API in a single request.")
+	cmd.Flags().Int("wlu-concurrency", 4, "How many requests to send to the API in parallel.")
+	cmd.Flags().Int("wlu-retries", 3, "How many times a failed request is retried.")
+}
+
+func readWLUOptions(cmd *cobra.Command) (WLUOptions, error) {
+	var opts WLUOptions
+	var err error
+	if opts.BatchSize, err = cmd.Flags().GetInt("wlu-batch-size"); err != nil {
+		return opts, err
+	}
+	if opts.Concurrency, err = cmd.Flags().GetInt("wlu-concurrency"); err != nil {
+		return opts, err
+	}
+	if opts.Retries, err = cmd.Flags().GetInt("wlu-retries"); err != nil {
+		return opts, err
+	}
+	if opts.BatchSize < 1 || opts.Concurrency < 1 || opts.Retries < 0 {
+		return opts, fmt.Errorf("batch size and concurrency must be positive and retries non-negative")
+	}
+	return opts, nil
+}
+
+// textsInBatches sends the requests in batches of opts.BatchSize, opts.Concurrency batches at a time. The
+// responses are returned in the order of the requests.
+func textsInBatches(ctx context.Context, client wluv1.WLUClient, appID string, requests []*wluv1.WLURequest, opts WLUOptions) (*wluv1.TextsResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	numBatches := (len(requests) + opts.BatchSize - 1) / opts.BatchSize
+	results := make([][]*wluv1.WLUResponse, numBatches)
+	batches := make(chan int)
+	bar := getBar("Annotating  ", "utt", len(requests))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				start := b * opts.BatchSize
+				end := start + opts.BatchSize
+				if end > len(requests) {
+					end = len(requests)
+				}
+				res, err := textsWithRetries(ctx, client, appID, requests[start:end], opts.Retries)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("utterances %d-%d: %v", start+1, end, err)
+						cancel()
+					})
+					continue
+				}
+				results[b] = res.Responses
+				_ = bar.Add(end - start)
+			}
+		}()
+	}
+
+	for b := 0; b < numBatches; b++ {
+		select {
+		case batches <- b:
+		case <-ctx.Done():
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		barClearOnError(bar)
+		return nil, firstErr
+	}
+	if err := bar.Close(); err != nil {
+		return nil, err
+	}
+
+	responses := make([]*wluv1.WLUResponse, 0, len(requests))
+	for _, r := range results {
+		responses = append(responses, r...)
+	}
+	return &wluv1.TextsResponse{Responses: responses}, nil
+}
+
+func textsWithRetries(ctx context.Context, client wluv1.WLUClient, appID string, requests []*wluv1.WLURequest, retries int) (*wluv1.TextsResponse, error) {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * wluRetryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		var res *wluv1.TextsResponse
+		res, err = client.Texts(ctx, &wluv1.TextsRequest{AppId: appID, Requests: requests})
+		if err == nil {
+			if len(res.Responses) != len(requests) {
+				return nil, fmt.Errorf("expected %d responses, got %d", len(requests), len(res.Responses))
+			}
+			return res, nil
+		}
+		if !isRetryable(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted, codes.Internal:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wluv1 "github.com/speechly/api/go/speechly/slu/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeWLUClient answers Texts calls with texts, counting the calls.
+type fakeWLUClient struct {
+	mu    sync.Mutex
+	calls int
+	texts func(ctx context.Context, call int, requests []*wluv1.WLURequest) (*wluv1.TextsResponse, error)
+}
+
+func (c *fakeWLUClient) Texts(ctx context.Context, in *wluv1.TextsRequest, opts ...grpc.CallOption) (*wluv1.TextsResponse, error) {
+	c.mu.Lock()
+	call := c.calls
+	c.calls++
+	c.mu.Unlock()
+	return c.texts(ctx, call, in.Requests)
+}
+
+// echoResponses returns a response with the text of each request as the annotated text.
+func echoResponses(requests []*wluv1.WLURequest) *wluv1.TextsResponse {
+	res := &wluv1.TextsResponse{}
+	for _, r := range requests {
+		res.Responses = append(res.Responses, &wluv1.WLUResponse{Segments: []*wluv1.WLUSegment{{AnnotatedText: r.Text}}})
+	}
+	return res
+}
+
+func wluRequests(n int) []*wluv1.WLURequest {
+	requests := make([]*wluv1.WLURequest, n)
+	for i := range requests {
+		requests[i] = &wluv1.WLURequest{Text: strconv.Itoa(i)}
+	}
+	return requests
+}
+
+func TestTextsInBatchesOrder(t *testing.T) {
+	requests := wluRequests(10)
+	client := &fakeWLUClient{texts: func(ctx context.Context, call int, requests []*wluv1.WLURequest) (*wluv1.TextsResponse, error) {
+		// the later batches finish first
+		first, _ := strconv.Atoi(requests[0].Text)
+		time.Sleep(time.Duration(10-first) * time.Millisecond)
+		return echoResponses(requests), nil
+	}}
+	res, err := textsInBatches(context.Background(), client, "app", requests, WLUOptions{BatchSize: 3, Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, r := range res.Responses {
+		texts = append(texts, r.Segments[0].AnnotatedText)
+	}
+	if expected := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}; !reflect.DeepEqual(expected, texts) {
+		t.Errorf("Expected responses in the order of the requests %v, got %v", expected, texts)
+	}
+	if client.calls != 4 {
+		t.Errorf("Expected 4 batches, got %d", client.calls)
+	}
+}
+
+func TestTextsWithRetries(t *testing.T) {
+	wluRetryDelay = time.Millisecond
+	defer func() {
+		wluRetryDelay = time.Second
+	}()
+	tests := []struct {
+		name     string
+		failures int
+		code     codes.Code
+		calls    int
+		expected codes.Code
+	}{
+		{"retried until success", 2, codes.Unavailable, 3, codes.OK},
+		{"retries exhausted", 5, codes.Unavailable, 3, codes.Unavailable},
+		{"not retried", 1, codes.InvalidArgument, 1, codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		client := &fakeWLUClient{texts: func(ctx context.Context, call int, requests []*wluv1.WLURequest) (*wluv1.TextsResponse, error) {
+			if call < test.failures {
+				return nil, status.Error(test.code, "failed")
+			}
+			return echoResponses(requests), nil
+		}}
+		_, err := textsWithRetries(context.Background(), client, "app", wluRequests(2), 2)
+		if code := status.Code(err); code != test.expected {
+			t.Errorf("%s: expected %s, got %v", test.name, test.expected, err)
+		}
+		if client.calls != test.calls {
+			t.Errorf("%s: expected %d calls, got %d", test.name, test.calls, client.calls)
+		}
+	}
+}
+
+func TestTextsWithRetriesResponseCount(t *testing.T) {
+	client := &fakeWLUClient{texts: func(ctx context.Context, call int, requests []*wluv1.WLURequest) (*wluv1.TextsResponse, error) {
+		return echoResponses(requests[:1]), nil
+	}}
+	_, err := textsWithRetries(context.Background(), client, "app", wluRequests(2), 2)
+	if err == nil || err.Error() != "expected 2 responses, got 1" {
+		t.Errorf("Expected a response count mismatch, got %v", err)
+	}
+	if client.calls != 1 {
+		t.Errorf("Expected a mismatch not to be retried, got %d calls", client.calls)
+	}
+}
+
+func TestTextsInBatchesFirstError(t *testing.T) {
+	var mu sync.Mutex
+	var notCancelled []string
+	client := &fakeWLUClient{texts: func(ctx context.Context, call int, requests []*wluv1.WLURequest) (*wluv1.TextsResponse, error) {
+		if requests[0].Text == "0" {
+			return nil, status.Error(codes.InvalidArgument, "bad request")
+		}
+		// the other batches only finish when cancelled
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(5 * time.Second):
+			mu.Lock()
+			notCancelled = append(notCancelled, requests[0].Text)
+			mu.Unlock()
+			return echoResponses(requests), nil
+		}
+	}}
+	res, err := textsInBatches(context.Background(), client, "app", wluRequests(10), WLUOptions{BatchSize: 1, Concurrency: 3})
+	if err == nil || !strings.Contains(err.Error(), "utterances 1-1") || !strings.Contains(err.Error(), "bad request") {
+		t.Errorf("Expected the error of the first batch, got %v and %v", res, err)
+	}
+	if len(notCancelled) > 0 {
+		t.Errorf("Expected the remaining batches to be cancelled, batches %v were not", notCancelled)
+	}
+}
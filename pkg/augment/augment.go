@@ -0,0 +1,264 @@
+// Package augment derives perturbed variants of audio signals for robustness evaluation. Signals are
+// mono samples in the range [-1, 1].
+//
+// The supported conditions are written as kind:parameter:
+//
+//	noise:10      additive noise at 10 dB signal-to-noise ratio
+//	gain:-12      gain change of -12 dB
+//	speed:1.1     speed perturbation by factor 1.1, which also changes the pitch
+//	clip:0.3      clipping at 30% of the peak amplitude
+//	telephony     300-3400 Hz band-limiting, 8 kHz resampling and 8 bit μ-law quantization
+package augment
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a perturbation.
+type Kind string
+
+const (
+	Noise     Kind = "noise"
+	Gain      Kind = "gain"
+	Speed     Kind = "speed"
+	Clip      Kind = "clip"
+	Telephony Kind = "telephony"
+)
+
+// Condition is a single perturbation with its parameter.
+type Condition struct {
+	Kind  Kind
+	Param float64
+}
+
+func (c Condition) String() string {
+	if c.Kind == Telephony {
+		return string(c.Kind)
+	}
+	return string(c.Kind) + ":" + strconv.FormatFloat(c.Param, 'g', -1, 64)
+}
+
+// ParseCondition parses a condition in the kind:parameter notation.
+func ParseCondition(s string) (Condition, error) {
+	kind, param, hasParam := strings.Cut(strings.TrimSpace(s), ":")
+	c := Condition{Kind: Kind(kind)}
+	if c.Kind == Telephony {
+		if hasParam {
+			return c, fmt.Errorf("telephony takes no parameter: %s", s)
+		}
+		return c, nil
+	}
+	if !hasParam {
+		return c, fmt.Errorf("missing parameter in %s, expected kind:parameter", s)
+	}
+	var err error
+	if c.Param, err = strconv.ParseFloat(param, 64); err != nil {
+		return c, fmt.Errorf("invalid parameter in %s: %v", s, err)
+	}
+	if math.IsNaN(c.Param) || math.IsInf(c.Param, 0) {
+		return c, fmt.Errorf("parameter must be finite: %s", s)
+	}
+	switch c.Kind {
+	case Noise, Gain:
+	case Speed:
+		if c.Param <= 0 {
+			return c, fmt.Errorf("speed factor must be positive: %s", s)
+		}
+	case Clip:
+		if c.Param <= 0 || c.Param > 1 {
+			return c, fmt.Errorf("clipping level must be in (0, 1]: %s", s)
+		}
+	default:
+		return c, fmt.Errorf("unknown condition %s", kind)
+	}
+	return c, nil
+}
+
+// Augmenter applies conditions to signals of the given sample rate. Noise conditions mix in a randomly
+// chosen segment of one of the noise signals.
+type Augmenter struct {
+	SampleRate int
+	Noises     [][]float64
+	Rand       *rand.Rand
+}
+
+// Apply returns a perturbed copy of the signal.
+func (a *Augmenter) Apply(c Condition, signal []float64) ([]float64, error) {
+	switch c.Kind {
+	case Noise:
+		if len(a.Noises) == 0 {
+			return nil, fmt.Errorf("no noise signals given for %s", c)
+		}
+		noise := a.Noises[a.Rand.Intn(len(a.Noises))]
+		if len(noise) == 0 {
+			return nil, fmt.Errorf("empty noise signal")
+		}
+		return AddNoise(signal, noise, a.Rand.Intn(len(noise)), c.Param), nil
+	case Gain:
+		return ApplyGain(signal, c.Param), nil
+	case Speed:
+		return ChangeSpeed(signal, c.Param), nil
+	case Clip:
+		return ClipPeaks(signal, c.Param), nil
+	case Telephony:
+		return TelephonyChannel(signal, a.SampleRate), nil
+	}
+	return nil, fmt.Errorf("unknown condition %s", c.Kind)
+}
+
+func power(signal []float64) float64 {
+	if len(signal) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range signal {
+		sum += x * x
+	}
+	return sum / float64(len(signal))
+}
+
+// AddNoise mixes the noise, looped from offset, into the signal at the given signal-to-noise ratio.
+func AddNoise(signal []float64, noise []float64, offset int, snrDB float64) []float64 {
+	looped := make([]float64, len(signal))
+	for i := range looped {
+		looped[i] = noise[(offset+i)%len(noise)]
+	}
+	out := make([]float64, len(signal))
+	pn := power(looped)
+	if pn == 0 {
+		copy(out, signal)
+		return out
+	}
+	k := math.Sqrt(power(signal) / (pn * math.Pow(10, snrDB/10)))
+	for i := range out {
+		out[i] = clamp(signal[i] + k*looped[i])
+	}
+	return out
+}
+
+// ApplyGain amplifies the signal by the given number of decibels. Samples out of range are clipped.
+func ApplyGain(signal []float64, db float64) []float64 {
+	k := math.Pow(10, db/20)
+	out := make([]float64, len(signal))
+	for i, x := range signal {
+		out[i] = clamp(k * x)
+	}
+	return out
+}
+
+// ChangeSpeed plays the signal faster by factor, shortening it accordingly.
+func ChangeSpeed(signal []float64, factor float64) []float64 {
+	return resample(signal, factor)
+}
+
+// ClipPeaks clips the signal at the given fraction of its peak amplitude.
+func ClipPeaks(signal []float64, level float64) []float64 {
+	peak := 0.0
+	for _, x := range signal {
+		peak = math.Max(peak, math.Abs(x))
+	}
+	t := level * peak
+	out := make([]float64, len(signal))
+	for i, x := range signal {
+		out[i] = math.Max(-t, math.Min(t, x))
+	}
+	return out
+}
+
+// TelephonyChannel simulates a narrowband telephone channel: the signal is band-limited to 300-3400 Hz,
+// resampled to 8 kHz, quantized with 8 bit μ-law and resampled back to the original rate.
+func TelephonyChannel(signal []float64, sampleRate int) []float64 {
+	filtered := highPass(signal, 300, sampleRate)
+	filtered = lowPass(filtered, 3400, sampleRate)
+	ratio := float64(sampleRate) / 8000
+	narrow := resample(filtered, ratio)
+	for i, x := range narrow {
+		narrow[i] = muLaw(x)
+	}
+	out := resample(narrow, 1/ratio)
+	if len(out) > len(signal) {
+		out = out[:len(signal)]
+	}
+	return out
+}
+
+// resample reads the signal with the given step using linear interpolation.
+func resample(signal []float64, step float64) []float64 {
+	if len(signal) == 0 {
+		return nil
+	}
+	n := int(float64(len(signal)) / step)
+	out := make([]float64, n)
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		if j >= len(signal)-1 {
+			out[i] = signal[len(signal)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = signal[j]*(1-frac) + signal[j+1]*frac
+	}
+	return out
+}
+
+// muLaw quantizes the sample to 8 bits with μ-law companding.
+func muLaw(x float64) float64 {
+	const mu = 255.0
+	y := math.Copysign(math.Log1p(mu*math.Abs(x))/math.Log1p(mu), x)
+	y = math.Round(y*127) / 127
+	return math.Copysign((math.Pow(1+mu, math.Abs(y))-1)/mu, y)
+}
+
+// biquad is a second order IIR filter with coefficients from the Audio EQ Cookbook.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+func (f biquad) apply(signal []float64) []float64 {
+	out := make([]float64, len(signal))
+	var x1, x2, y1, y2 float64
+	for i, x := range signal {
+		y := f.b0*x + f.b1*x1 + f.b2*x2 - f.a1*y1 - f.a2*y2
+		x2, x1 = x1, x
+		y2, y1 = y1, y
+		out[i] = y
+	}
+	return out
+}
+
+func lowPass(signal []float64, cutoff float64, sampleRate int) []float64 {
+	w := 2 * math.Pi * cutoff / float64(sampleRate)
+	alpha := math.Sin(w) / math.Sqrt2 // Q = 1/√2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}.apply(signal)
+}
+
+func highPass(signal []float64, cutoff float64, sampleRate int) []float64 {
+	w := 2 * math.Pi * cutoff / float64(sampleRate)
+	alpha := math.Sin(w) / math.Sqrt2 // Q = 1/√2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}.apply(signal)
+}
+
+func clamp(x float64) float64 {
+	return math.Max(-1, math.Min(1, x))
+}
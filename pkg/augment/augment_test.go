@@ -0,0 +1,78 @@
+package augment_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/speechly/cli/pkg/augment"
+)
+
+func sine(freq float64, n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/16000)
+	}
+	return s
+}
+
+func rms(s []float64) float64 {
+	sum := 0.0
+	for _, x := range s {
+		sum += x * x
+	}
+	return math.Sqrt(sum / float64(len(s)))
+}
+
+func TestParseCondition(t *testing.T) {
+	for _, s := range []string{"noise:10", "gain:-6", "speed:1.1", "clip:0.3", "telephony"} {
+		c, err := augment.ParseCondition(s)
+		if err != nil {
+			t.Errorf("Parsing %s failed: %v", s, err)
+			continue
+		}
+		if c.String() != s {
+			t.Errorf("Condition %s was formatted as %s", s, c)
+		}
+	}
+	for _, s := range []string{"noise", "speed:0", "clip:2", "telephony:1", "reverb:1", "gain:x",
+		"speed:NaN", "speed:Inf", "gain:NaN", "gain:-Inf", "clip:NaN", "noise:+Inf"} {
+		if _, err := augment.ParseCondition(s); err == nil {
+			t.Errorf("Expected an error for %s", s)
+		}
+	}
+}
+
+func TestAddNoise(t *testing.T) {
+	signal := sine(440, 16000)
+	r := rand.New(rand.NewSource(1))
+	noise := make([]float64, 4000)
+	for i := range noise {
+		noise[i] = r.Float64()*0.2 - 0.1
+	}
+	mixed := augment.AddNoise(signal, noise, 123, 10)
+	diff := make([]float64, len(signal))
+	for i := range diff {
+		diff[i] = mixed[i] - signal[i]
+	}
+	snr := 20 * math.Log10(rms(signal)/rms(diff))
+	if math.Abs(snr-10) > 0.1 {
+		t.Errorf("SNR should be 10 dB but was %.2f dB", snr)
+	}
+}
+
+func TestSpeedAndTelephony(t *testing.T) {
+	signal := sine(440, 16000)
+	if n := len(augment.ChangeSpeed(signal, 2)); n != 8000 {
+		t.Errorf("Speed 2 should halve the length, got %d samples", n)
+	}
+	low := augment.TelephonyChannel(sine(100, 16000), 16000)
+	mid := augment.TelephonyChannel(signal, 16000)
+	high := augment.TelephonyChannel(sine(7000, 16000), 16000)
+	if len(mid) != len(signal) {
+		t.Errorf("Telephony should keep the length, got %d samples", len(mid))
+	}
+	if rms(low) > rms(mid)/2 || rms(high) > rms(mid)/2 {
+		t.Errorf("Telephony should attenuate frequencies out of band: 100 Hz %.3f, 440 Hz %.3f, 7 kHz %.3f", rms(low), rms(mid), rms(high))
+	}
+}
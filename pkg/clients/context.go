@@ -47,10 +47,19 @@ func (cc *connectionCache) getConnection(ctx context.Context) *grpc.ClientConn {
 	if cc.conn != nil {
 		return cc.conn
 	}
-	if cc.sc.Host == "" {
-		cc.ff(errors.New("no API host defined"))
+	conn, err := cc.dial(ctx)
+	if err != nil {
+		cc.ff(err)
 		return nil
 	}
+	cc.conn = conn
+	return cc.conn
+}
+
+func (cc *connectionCache) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	if cc.sc.Host == "" {
+		return nil, errors.New("no API host defined")
+	}
 	serverAddr := cc.sc.Host
 	opts := []grpc.DialOption{grpc.WithBlock()}
 	creds := insecure.NewCredentials()
@@ -67,11 +76,9 @@ func (cc *connectionCache) getConnection(ctx context.Context) *grpc.ClientConn {
 	defer cancel()
 	conn, err := grpc.DialContext(connCtx, serverAddr, opts...)
 	if err != nil {
-		cc.ff(fmt.Errorf("connecting to host %s failed: %v", cc.sc.Host, err))
-		return nil
+		return nil, fmt.Errorf("connecting to host %s failed: %v", cc.sc.Host, err)
 	}
-	cc.conn = conn
-	return cc.conn
+	return conn, nil
 }
 
 func NewContext(ff FailFunc) context.Context {
@@ -98,6 +105,24 @@ func NewContext(ff FailFunc) context.Context {
 	return ctx
 }
 
+// WithHost returns a context whose clients connect to the given host instead of the host of the current
+// project. The API token of the current project, if any, is still used.
+func WithHost(ctx context.Context, host string) context.Context {
+	sc := SpeechlyContext{Name: host}
+	ff, _ := ctx.Value(keyFailFunc).(FailFunc)
+	if cc, ok := ctx.Value(keyClientConnection).(*connectionCache); ok {
+		sc = *cc.sc
+		ff = cc.ff
+	}
+	if ff == nil {
+		ff = func(err error) {
+			log.Fatalf("error: %v", err)
+		}
+	}
+	sc.Host = host
+	return context.WithValue(ctx, keyClientConnection, &connectionCache{sc: &sc, ff: ff})
+}
+
 func GetConfig(ctx context.Context) *Config {
 	config, ok := ctx.Value(keySpeechlyConfig).(*Config)
 	if !ok {
@@ -154,6 +179,32 @@ func SLUClient(ctx context.Context) (sluv1.SLUClient, error) {
 	return sluv1.NewSLUClient(cc.getConnection(ctx)), nil
 }
 
+// SLUClientPool returns n SLU clients, each using a connection of its own, for spreading a large number
+// of concurrent streams over several connections. The returned function closes the connections.
+func SLUClientPool(ctx context.Context, n int) ([]sluv1.SLUClient, func(), error) {
+	cc, ok := ctx.Value(keyClientConnection).(*connectionCache)
+	if !ok {
+		return nil, nil, errors.New("invalid project")
+	}
+	var conns []*grpc.ClientConn
+	closeAll := func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}
+	pool := make([]sluv1.SLUClient, n)
+	for i := range pool {
+		conn, err := cc.dial(ctx)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		conns = append(conns, conn)
+		pool[i] = sluv1.NewSLUClient(conn)
+	}
+	return pool, closeAll, nil
+}
+
 func BatchAPIClient(ctx context.Context) (sluv1.BatchAPIClient, error) {
 	cc, ok := ctx.Value(keyClientConnection).(*connectionCache)
 	if !ok {
@@ -0,0 +1,174 @@
+package sal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError describes a malformed annotation. Offset is the byte offset of the error in Input.
+type SyntaxError struct {
+	Input  string
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	start := e.Offset - 20
+	if start < 0 {
+		start = 0
+	}
+	end := e.Offset + 20
+	if end > len(e.Input) {
+		end = len(e.Input)
+	}
+	return fmt.Sprintf("%s at offset %d near %q", e.Msg, e.Offset, e.Input[start:end])
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+// Parse parses a single annotated utterance.
+func Parse(input string) (Utterance, error) {
+	p := &parser{input: input}
+	return p.parse()
+}
+
+// MustParse is like Parse but panics if the input cannot be parsed.
+func MustParse(input string) Utterance {
+	u, err := Parse(input)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (p *parser) errorf(offset int, format string, args ...interface{}) error {
+	return &SyntaxError{Input: p.input, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parse() (Utterance, error) {
+	var u Utterance
+	current := Segment{Start: 0}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			break
+		}
+		start := p.pos
+		switch p.input[p.pos] {
+		case '*':
+			name := p.readWord(start + 1)
+			if name == "" {
+				return u, p.errorf(start, "missing intent name after '*'")
+			}
+			if current.Intent != "" || len(current.Nodes) > 0 {
+				current.End = lastEnd(current)
+				u.Segments = append(u.Segments, current)
+			}
+			current = Segment{Intent: name, Start: start, End: p.pos}
+		case '[':
+			n, err := p.parseEntity()
+			if err != nil {
+				return u, err
+			}
+			current.Nodes = append(current.Nodes, n)
+		case '(':
+			end := strings.IndexByte(p.input[start:], ')')
+			if end < 0 {
+				return u, p.errorf(start, "unterminated removable token, expected ')'")
+			}
+			p.pos = start + end + 1
+			current.Nodes = append(current.Nodes, Node{Kind: RemovableNode, Text: p.input[start+1 : start+end], Start: start, End: p.pos})
+		case ']', ')', '|':
+			return u, p.errorf(start, "unexpected '%c'", p.input[start])
+		default:
+			word := p.readWord(start)
+			current.Nodes = append(current.Nodes, Node{Kind: WordNode, Text: word, Start: start, End: p.pos})
+		}
+	}
+	if current.Intent != "" || len(current.Nodes) > 0 {
+		current.End = lastEnd(current)
+		u.Segments = append(u.Segments, current)
+	}
+	return u, nil
+}
+
+func (p *parser) parseEntity() (Node, error) {
+	start := p.pos
+	n := Node{Kind: EntityNode, Start: start}
+	textEnd := strings.IndexAny(p.input[start+1:], "[]|()")
+	if textEnd < 0 {
+		return n, p.errorf(start, "unterminated entity, expected ']'")
+	}
+	textEnd += start + 1
+	n.Text = strings.TrimSpace(p.input[start+1 : textEnd])
+	switch p.input[textEnd] {
+	case '|':
+		valueEnd := strings.IndexAny(p.input[textEnd+1:], "[]|()")
+		if valueEnd < 0 || p.input[textEnd+1+valueEnd] != ']' {
+			return n, p.errorf(start, "unterminated entity value, expected ']'")
+		}
+		valueEnd += textEnd + 1
+		n.Value = strings.TrimSpace(p.input[textEnd+1 : valueEnd])
+		if n.Value == "" {
+			return n, p.errorf(textEnd, "empty normalized value after '|'")
+		}
+		textEnd = valueEnd
+	case ']':
+	default:
+		return n, p.errorf(textEnd, "unexpected '%c' in entity", p.input[textEnd])
+	}
+	if n.Text == "" {
+		return n, p.errorf(start, "empty entity text")
+	}
+	typeStart := textEnd + 1
+	if typeStart >= len(p.input) || p.input[typeStart] != '(' {
+		return n, p.errorf(typeStart, "expected '(' and entity type after ']'")
+	}
+	typeEnd := strings.IndexByte(p.input[typeStart:], ')')
+	if typeEnd < 0 {
+		return n, p.errorf(typeStart, "unterminated entity type, expected ')'")
+	}
+	typeEnd += typeStart
+	n.Type = strings.TrimSpace(p.input[typeStart+1 : typeEnd])
+	if n.Type == "" {
+		return n, p.errorf(typeStart, "empty entity type")
+	}
+	p.pos = typeEnd + 1
+	n.End = p.pos
+	return n, nil
+}
+
+// readWord reads from start until whitespace or an annotation delimiter.
+func (p *parser) readWord(start int) string {
+	p.pos = start
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if unicode.IsSpace(r) || strings.ContainsRune("[]()|", r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func lastEnd(s Segment) int {
+	if len(s.Nodes) == 0 {
+		return s.End
+	}
+	return s.Nodes[len(s.Nodes)-1].End
+}
@@ -0,0 +1,189 @@
+// Package sal parses SAL annotated utterances, such as the ones produced by `speechly annotate` and
+// `speechly sample`:
+//
+//	*turn_on turn on the [lights](device) in the [living room|LIVING_ROOM](room) *turn_off and off (noise)
+//
+// An utterance consists of segments, each starting with an *intent tag. Segments contain plain words,
+// entities in the form [text](type) or [text|normalized value](type) and removable tokens in
+// parentheses, which are not part of the transcript.
+package sal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeKind is the type of a node inside a segment.
+type NodeKind int
+
+const (
+	WordNode NodeKind = iota
+	EntityNode
+	RemovableNode
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case WordNode:
+		return "word"
+	case EntityNode:
+		return "entity"
+	case RemovableNode:
+		return "removable"
+	}
+	return fmt.Sprintf("NodeKind(%d)", int(k))
+}
+
+// Node is a word, an entity or a removable token. Start and End are byte offsets to the parsed input.
+type Node struct {
+	Kind NodeKind
+	// Text is the word, the surface text of an entity or the contents of a removable token.
+	Text string
+	// Value is the normalized value of an entity, if one was given.
+	Value string
+	// Type is the entity type.
+	Type  string
+	Start int
+	End   int
+}
+
+// NormalizedValue returns the normalized value of an entity, or its surface text if none was given.
+func (n Node) NormalizedValue() string {
+	if n.Value != "" {
+		return n.Value
+	}
+	return n.Text
+}
+
+func (n Node) String() string {
+	switch n.Kind {
+	case EntityNode:
+		if n.Value != "" {
+			return "[" + n.Text + "|" + n.Value + "](" + n.Type + ")"
+		}
+		return "[" + n.Text + "](" + n.Type + ")"
+	case RemovableNode:
+		return "(" + n.Text + ")"
+	}
+	return n.Text
+}
+
+// Segment is a part of an utterance with a single intent. Text before the first intent tag is in a
+// segment with an empty Intent. Start and End are byte offsets to the parsed input.
+type Segment struct {
+	Intent string
+	Nodes  []Node
+	Start  int
+	End    int
+}
+
+// Entities returns the entity nodes of the segment.
+func (s Segment) Entities() []Node {
+	var entities []Node
+	for _, n := range s.Nodes {
+		if n.Kind == EntityNode {
+			entities = append(entities, n)
+		}
+	}
+	return entities
+}
+
+// Text returns the transcript of the segment without any annotations.
+func (s Segment) Text() string {
+	var words []string
+	for _, n := range s.Nodes {
+		if n.Kind != RemovableNode {
+			words = append(words, n.Text)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (s Segment) String() string {
+	parts := make([]string, 0, len(s.Nodes)+1)
+	if s.Intent != "" {
+		parts = append(parts, "*"+s.Intent)
+	}
+	for _, n := range s.Nodes {
+		parts = append(parts, n.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Utterance is a parsed annotated utterance.
+type Utterance struct {
+	Segments []Segment
+}
+
+// Text returns the transcript of the utterance without any annotations.
+func (u Utterance) Text() string {
+	var texts []string
+	for _, s := range u.Segments {
+		if t := s.Text(); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Intents returns the intents of the segments in order.
+func (u Utterance) Intents() []string {
+	var intents []string
+	for _, s := range u.Segments {
+		if s.Intent != "" {
+			intents = append(intents, s.Intent)
+		}
+	}
+	return intents
+}
+
+// Entities returns the entities of all segments in order.
+func (u Utterance) Entities() []Node {
+	var entities []Node
+	for _, s := range u.Segments {
+		entities = append(entities, s.Entities()...)
+	}
+	return entities
+}
+
+// String formats the utterance in canonical SAL notation, with single spaces between tokens.
+func (u Utterance) String() string {
+	parts := make([]string, 0, len(u.Segments))
+	for _, s := range u.Segments {
+		if str := s.String(); str != "" {
+			parts = append(parts, str)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// WithoutValues returns a copy of the utterance with the normalized entity values removed.
+func (u Utterance) WithoutValues() Utterance {
+	segments := make([]Segment, len(u.Segments))
+	for i, s := range u.Segments {
+		nodes := make([]Node, len(s.Nodes))
+		for j, n := range s.Nodes {
+			n.Value = ""
+			nodes[j] = n
+		}
+		s.Nodes = nodes
+		segments[i] = s
+	}
+	return Utterance{Segments: segments}
+}
+
+// WithoutRemovables returns a copy of the utterance with the removable tokens removed.
+func (u Utterance) WithoutRemovables() Utterance {
+	segments := make([]Segment, len(u.Segments))
+	for i, s := range u.Segments {
+		var nodes []Node
+		for _, n := range s.Nodes {
+			if n.Kind != RemovableNode {
+				nodes = append(nodes, n)
+			}
+		}
+		s.Nodes = nodes
+		segments[i] = s
+	}
+	return Utterance{Segments: segments}
+}
@@ -0,0 +1,89 @@
+package sal_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/speechly/cli/pkg/sal"
+)
+
+func TestParse(t *testing.T) {
+	input := `*turn_on turn on the [lights](device) in the [living room|LIVING_ROOM](room) *turn_off (noise) and [tv](device)`
+	u, err := sal.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := u.Intents(); !reflect.DeepEqual(got, []string{"turn_on", "turn_off"}) {
+		t.Errorf("Unexpected intents %v", got)
+	}
+	if got, want := u.Text(), "turn on the lights in the living room and tv"; got != want {
+		t.Errorf("Text should be %q but was %q", want, got)
+	}
+	if got := u.String(); got != input {
+		t.Errorf("String should be %q but was %q", input, got)
+	}
+
+	entities := u.Entities()
+	if len(entities) != 3 {
+		t.Fatalf("Expected 3 entities, got %d", len(entities))
+	}
+	room := entities[1]
+	if room.Text != "living room" || room.Value != "LIVING_ROOM" || room.Type != "room" {
+		t.Errorf("Unexpected entity %+v", room)
+	}
+	if got := input[room.Start:room.End]; got != "[living room|LIVING_ROOM](room)" {
+		t.Errorf("Entity offsets point to %q", got)
+	}
+	if entities[0].NormalizedValue() != "lights" {
+		t.Errorf("Normalized value should default to the entity text, was %q", entities[0].NormalizedValue())
+	}
+	if got := input[u.Segments[1].Start:u.Segments[1].End]; got != "*turn_off (noise) and [tv](device)" {
+		t.Errorf("Segment offsets point to %q", got)
+	}
+
+	if got, want := u.WithoutValues().String(), `*turn_on turn on the [lights](device) in the [living room](room) *turn_off (noise) and [tv](device)`; got != want {
+		t.Errorf("WithoutValues should be %q but was %q", want, got)
+	}
+	if got, want := u.WithoutRemovables().String(), `*turn_on turn on the [lights](device) in the [living room|LIVING_ROOM](room) *turn_off and [tv](device)`; got != want {
+		t.Errorf("WithoutRemovables should be %q but was %q", want, got)
+	}
+}
+
+func TestParseWithoutIntent(t *testing.T) {
+	u, err := sal.Parse("  just some\twords  ")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(u.Segments) != 1 || u.Segments[0].Intent != "" {
+		t.Errorf("Expected a single segment without intent, got %+v", u.Segments)
+	}
+	if got := u.Text(); got != "just some words" {
+		t.Errorf("Unexpected text %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]int{
+		"*intent [lights(device)":    15,
+		"*intent [lights]":           16,
+		"*intent [lights](device":    16,
+		"*intent [lights|](device)":  15,
+		"*intent [](device)":         8,
+		"*intent [lights]()":         16,
+		"* missing intent":           0,
+		"*intent stray ] bracket":    14,
+		"*intent unterminated (oops": 21,
+	}
+	for input, offset := range cases {
+		_, err := sal.Parse(input)
+		var se *sal.SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Expected syntax error for %q, got %v", input, err)
+			continue
+		}
+		if se.Offset != offset {
+			t.Errorf("Error for %q should be at offset %d but was at %d: %v", input, offset, se.Offset, se)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy decides what is done when an extracted file exists with different contents.
+type ConflictPolicy string
+
+const (
+	// OverwriteOnConflict replaces the existing file.
+	OverwriteOnConflict ConflictPolicy = "overwrite"
+	// SkipOnConflict keeps the existing file.
+	SkipOnConflict ConflictPolicy = "skip"
+	// BackupOnConflict renames the existing file with a .bak suffix before writing the new one.
+	BackupOnConflict ConflictPolicy = "backup"
+)
+
+// ParseConflictPolicy returns the policy with the given name.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(name); p {
+	case OverwriteOnConflict, SkipOnConflict, BackupOnConflict:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %s, expected skip, overwrite or backup", name)
+}
+
+// TarFile is a regular file of a tar package.
+type TarFile struct {
+	// Name is the slash separated path of the file relative to the package.
+	Name     string
+	Mode     os.FileMode
+	Contents []byte
+}
+
+// ReadTar reads the regular files of a tar package. Other entries, such as directories and links, are
+// skipped. Fails if a name is absolute or refers outside of the package, so that nothing is extracted
+// from a package with such names.
+func ReadTar(r io.Reader) ([]TarFile, error) {
+	tr := tar.NewReader(r)
+	var files []TarFile
+	for {
+		header, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return files, nil
+		case err != nil:
+			return nil, err
+		case header == nil:
+			continue // skip empty files in tar
+		}
+		name, err := localName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		mode := header.FileInfo().Mode().Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		files = append(files, TarFile{Name: name, Mode: mode, Contents: contents})
+	}
+}
+
+// localName cleans the name of a tar entry, and fails if it is not a relative path inside the package.
+func localName(name string) (string, error) {
+	clean := path.Clean(name)
+	switch {
+	case name == "" || clean == ".":
+		return "", fmt.Errorf("invalid file name %q in package", name)
+	case path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", fmt.Errorf("file name %q in package refers outside of the target directory", name)
+	case strings.Contains(clean, `\`) || filepath.VolumeName(filepath.FromSlash(clean)) != "":
+		return "", fmt.Errorf("file name %q in package is not a portable path", name)
+	}
+	return clean, nil
+}
+
+// WriteFiles writes the files to the directory. Files that exist with different contents are handled
+// according to the policy. Fails without writing anything if a directory of a file path inside the directory
+// is a symbolic link or not a directory, so that no files are written outside of the directory.
+func WriteFiles(outDir string, files []TarFile, policy ConflictPolicy) error {
+	for _, file := range files {
+		if err := checkParents(outDir, file.Name); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		target := filepath.Join(outDir, filepath.FromSlash(file.Name))
+		info, err := os.Lstat(target)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("%s exists and is not a regular file", target)
+		default:
+			existing, err := os.ReadFile(target)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(existing, file.Contents) {
+				fmt.Printf("File %s is up to date\n", target)
+				continue
+			}
+			switch policy {
+			case SkipOnConflict:
+				fmt.Printf("Skipping file %s, the local file differs\n", target)
+				continue
+			case BackupOnConflict:
+				backup := backupName(target)
+				fmt.Printf("Backing up file %s to %s\n", target, backup)
+				if err := os.Rename(target, backup); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Printf("Writing file %s (%d bytes)\n", target, len(file.Contents))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, file.Contents, file.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParents fails if an existing directory of the slash separated name inside outDir is a symbolic link
+// or not a directory.
+func checkParents(outDir string, name string) error {
+	dir := outDir
+	parts := strings.Split(name, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%s is a symbolic link, not writing %s through it", dir, name)
+		case !info.IsDir():
+			return fmt.Errorf("%s exists and is not a directory", dir)
+		}
+	}
+	return nil
+}
+
+// backupName returns the first of target.bak, target.bak.1, target.bak.2, ... that does not exist.
+func backupName(target string) string {
+	backup := target + ".bak"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); errors.Is(err, fs.ErrNotExist) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s.bak.%d", target, i)
+	}
+}
+
+// ExtractTarToDir extracts the regular files of the tar package to the directory. Files that exist with
+// different contents are handled according to the policy.
+func ExtractTarToDir(outDir string, r io.Reader, policy ConflictPolicy) error {
+	files, err := ReadTar(r)
+	if err != nil {
+		return err
+	}
+	return WriteFiles(outDir, files, policy)
+}
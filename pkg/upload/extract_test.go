@@ -0,0 +1,105 @@
+package upload_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/speechly/cli/pkg/upload"
+)
+
+type tarEntry struct {
+	name     string
+	contents string
+}
+
+func tarOf(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0600, Size: int64(len(e.contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTarToDirRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"../config.yaml", "entities/../../config.yaml", "/etc/config.yaml", ".."} {
+		dir := t.TempDir()
+		r := tarOf(t, []tarEntry{{"config.yaml", "templates: ''"}, {name, "templates: ''"}})
+		if err := upload.ExtractTarToDir(filepath.Join(dir, "out"), r, upload.OverwriteOnConflict); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			t.Errorf("Expected nothing to be extracted from a package with %s", name)
+		}
+	}
+}
+
+func TestExtractTarToDirRejectsSymlinkedDirs(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(out, "entities")); err != nil {
+		t.Skipf("Creating a symbolic link failed: %v", err)
+	}
+	r := tarOf(t, []tarEntry{{"config.yaml", "templates: ''"}, {"entities/cities.csv", "helsinki"}})
+	if err := upload.ExtractTarToDir(out, r, upload.OverwriteOnConflict); err == nil {
+		t.Error("Expected a symbolic link to a directory to be rejected")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Errorf("Expected nothing to be written through the link, got %v", entries)
+	}
+	if _, err := os.Lstat(filepath.Join(out, "config.yaml")); err == nil {
+		t.Error("Expected nothing to be extracted from the package")
+	}
+}
+
+func TestExtractTarToDirOnConflict(t *testing.T) {
+	for _, test := range []struct {
+		policy   upload.ConflictPolicy
+		expected string
+		backup   bool
+	}{
+		{upload.OverwriteOnConflict, "deployed", false},
+		{upload.SkipOnConflict, "local", false},
+		{upload.BackupOnConflict, "deployed", true},
+	} {
+		dir := t.TempDir()
+		for _, name := range []string{"config.yaml", "same.yaml"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("local"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := tarOf(t, []tarEntry{{"config.yaml", "deployed"}, {"same.yaml", "local"}, {"entities/cities.csv", "helsinki"}})
+		if err := upload.ExtractTarToDir(dir, r, test.policy); err != nil {
+			t.Fatal(err)
+		}
+		for name, expected := range map[string]string{"config.yaml": test.expected, "same.yaml": "local", "entities/cities.csv": "helsinki"} {
+			if contents, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(contents) != expected {
+				t.Errorf("With %s, expected %s to contain %q, got %q (%v)", test.policy, name, expected, contents, err)
+			}
+		}
+		backup, err := os.ReadFile(filepath.Join(dir, "config.yaml.bak"))
+		if test.backup && string(backup) != "local" {
+			t.Errorf("With %s, expected a backup of the local file, got %q (%v)", test.policy, backup, err)
+		}
+		if !test.backup && err == nil {
+			t.Errorf("With %s, expected no backup", test.policy)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "same.yaml.bak")); err == nil {
+			t.Errorf("With %s, expected no backup of an unchanged file", test.policy)
+		}
+	}
+}
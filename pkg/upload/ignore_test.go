@@ -0,0 +1,49 @@
+package upload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"negation after pattern", "*.csv\n!keep.csv", "keep.csv", false, false},
+		{"negation before pattern", "!keep.csv\n*.csv", "keep.csv", false, true},
+		{"negation of other file", "*.csv\n!keep.csv", "drop.csv", false, true},
+		{"leading ** at top level", "**/old", "old", true, true},
+		{"leading ** nested", "**/old", "a/b/old", true, true},
+		{"trailing ** contents", "nested/**", "nested/a/b.csv", false, true},
+		{"trailing ** not the directory", "nested/**", "nested", true, false},
+		{"middle ** no directories", "a/**/b.csv", "a/b.csv", false, true},
+		{"middle ** many directories", "a/**/b.csv", "a/x/y/b.csv", false, true},
+		{"** inside a name", "a**b.csv", "axyb.csv", false, true},
+		{"** inside a name not across directories", "a**b.csv", "a/b.csv", false, false},
+		{"** after a slash inside a name", "x/**b.csv", "x/ab.csv", false, true},
+		{"** after a slash inside a name not across directories", "x/**b.csv", "x/a/b.csv", false, false},
+		{"dir-only matches directory", "build/", "build", true, true},
+		{"dir-only matches nested directory", "build/", "a/build", true, true},
+		{"dir-only skips file", "build/", "build", false, false},
+		{"anchored", "/build", "a/build", true, false},
+		{"escaped !", `\!important.csv`, "!important.csv", false, true},
+		{"escaped #", `\#notes.csv`, "#notes.csv", false, true},
+		{"comment", "#notes.csv", "#notes.csv", false, false},
+		{"character class", "[!a]b.csv", "cb.csv", false, true},
+		{"negated character class", "[!a]b.csv", "ab.csv", false, false},
+	}
+	for _, test := range tests {
+		rules, err := parseIgnoreRules(strings.NewReader(test.rules))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if result := rules.ignored(test.path, test.isDir); result != test.expected {
+			t.Errorf("%s: expected %q to be ignored %v by %q, got %v", test.name, test.path, test.expected, test.rules, result)
+		}
+	}
+}
@@ -2,92 +2,163 @@ package upload
 
 import (
 	"archive/tar"
-	"bytes"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 )
 
+// ChunkSize is the size of the chunks the tar package is streamed in.
+const ChunkSize = 1000000
+
+// UploadData is a tar package of the configuration files of a directory. The package is not kept in memory,
+// but created from the files each time it is streamed.
 type UploadData struct {
-	Files []string
-	Buf   bytes.Buffer
+	Files []UploadFile
+	// Size is the total size of the files.
+	Size int64
+}
+
+// UploadFile is a configuration file to be uploaded.
+type UploadFile struct {
+	// Name is the slash separated path of the file relative to the configuration directory, used as the
+	// name of the file in the tar package.
+	Name string
+	Path string
+	Size int64
 }
 
+// configFileExtensions are the extensions of the files accepted in the tar package.
+var configFileExtensions = map[string]bool{".csv": true, ".yaml": true}
+
+// ListFiles returns the configuration files in the directory and its subdirectories, excluding the paths
+// ignored by the .speechlyignore file in the directory and the .git directory. The files are in lexical order.
+func ListFiles(inDir string) ([]UploadFile, error) {
+	var rules ignoreRules
+	if f, err := os.Open(filepath.Join(inDir, IgnoreFileName)); err == nil {
+		rules, err = parseIgnoreRules(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", IgnoreFileName, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var files []UploadFile
+	err := filepath.WalkDir(inDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(inDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || rules.ignored(name, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// symbolic links to files are followed, links to directories are not
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !configFileExtensions[filepath.Ext(name)] || rules.ignored(name, false) {
+			return nil
+		}
+		files = append(files, UploadFile{Name: name, Path: path, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// CreateTarFromDir lists the configuration files in the directory to be packaged.
 func CreateTarFromDir(inDir string) UploadData {
-	files, err := os.ReadDir(inDir)
+	files, err := ListFiles(inDir)
 	if err != nil {
 		log.Fatalf("Could not read files from %s: %s", inDir, err)
 	}
-	// only accept yaml and csv files in the tar package
-	configFileMatch := regexp.MustCompile(`.*?(csv|yaml)$`)
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
-	uploadFiles := []string{}
+	ud := UploadData{Files: files}
 	for _, f := range files {
-		if configFileMatch.MatchString(f.Name()) {
-			info, err := f.Info()
-			if err != nil {
-				log.Fatalf("Failed to read file: %s", err)
-			}
-			log.Printf("Adding %s (%d bytes)\n", f.Name(), info.Size())
-			hdr := &tar.Header{
-				Name: info.Name(),
-				Mode: 0600,
-				Size: info.Size(),
-			}
-			if err := tw.WriteHeader(hdr); err != nil {
-				log.Fatalf("Failed to create a tar header: %s", err)
-			}
-			uploadFile := filepath.Join(inDir, f.Name())
-			contents, err := os.ReadFile(uploadFile)
-			if err != nil {
-				log.Fatalf("Failed to read file: %s", err)
-			}
-			if _, err := tw.Write(contents); err != nil {
-				log.Fatalf("Failed to tar file: %s", err)
-			}
-			uploadFiles = append(uploadFiles, uploadFile)
-		}
+		log.Printf("Adding %s (%d bytes)\n", f.Name, f.Size)
+		ud.Size += f.Size
 	}
-	if err := tw.Close(); err != nil {
-		log.Fatalf("Package finalization failed: %s", err)
+	return ud
+}
+
+// Stream writes the tar package to w in chunks of ChunkSize bytes. The files are read only as the package
+// is consumed through a pipe, so that at most a chunk of it is in memory at a time. The contents of the
+// files are also written to progress, if given. Returns the number of bytes written and the hex encoded
+// SHA-256 checksum of the package.
+func (u UploadData) Stream(w io.Writer, progress io.Writer) (int64, string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(u.writeTar(pw, progress))
+	}()
+
+	h := sha256.New()
+	bw := bufio.NewWriterSize(w, ChunkSize)
+	n, err := io.CopyBuffer(io.MultiWriter(bw, h), pr, make([]byte, ChunkSize))
+	if err == nil {
+		err = bw.Flush()
+	}
+	// stop the writer if the upload failed
+	pr.CloseWithError(err)
+	if err != nil {
+		return n, "", err
 	}
-	return UploadData{uploadFiles, buf}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func ExtractTarToDir(outDir string, r io.Reader) error {
-	tr := tar.NewReader(r)
-	for {
-		header, err := tr.Next()
-		switch {
-		case err == io.EOF:
-			return nil
-		case err != nil:
+func (u UploadData) writeTar(w io.Writer, progress io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, uf := range u.Files {
+		if err := writeTarFile(tw, uf, progress); err != nil {
 			return err
-		case header == nil:
-			continue // skip empty files in tar
-		}
-		target := filepath.Join(outDir, header.Name)
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if _, err := os.Stat(target); err != nil {
-				if err := os.MkdirAll(target, 0755); err != nil {
-					return err
-				}
-			}
-		case tar.TypeReg:
-			fmt.Printf("Writing file %s (%d bytes)\n", target, header.Size)
-			f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(f, tr); err != nil {
-				return err
-			}
-			_ = f.Close()
 		}
 	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, uf UploadFile, progress io.Writer) error {
+	f, err := os.Open(uf.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	hdr := &tar.Header{
+		Name: uf.Name,
+		Mode: 0600,
+		Size: info.Size(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to create a tar header: %w", err)
+	}
+	var r io.Reader = f
+	if progress != nil {
+		r = io.TeeReader(f, progress)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to tar file %s: %w", uf.Name, err)
+	}
+	return nil
 }
@@ -0,0 +1,127 @@
+package upload_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/speechly/cli/pkg/upload"
+)
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml":                     "templates: ''",
+		"notes_yaml":                      "not a config file",
+		"README.md":                       "readme",
+		"entities/cities.csv":             "helsinki",
+		"entities/streets.csv":            "mannerheimintie",
+		"entities/old/cities.csv":         "turku",
+		"drafts/draft.yaml":               "templates: ''",
+		"drafts/keep.yaml":                "templates: ''",
+		"build/config.yaml":               "templates: ''",
+		"nested/build/config.yaml":        "templates: ''",
+		"nested/tmp.csv":                  "tmp",
+		"nested/deep/er/backup.csv":       "backup",
+		".git/config.yaml":                "templates: ''",
+		upload.IgnoreFileName:             "# comments are skipped\n/build/\ndrafts/*\n!drafts/keep.yaml\n**/old\ntmp.csv\nnested/**/backup.csv\nentities/s?reets.csv\n",
+		"nested/" + upload.IgnoreFileName: "config.yaml\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := upload.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range result {
+		names = append(names, f.Name)
+		if f.Size != int64(len(files[f.Name])) {
+			t.Errorf("Expected %s to have size %d, got %d", f.Name, len(files[f.Name]), f.Size)
+		}
+	}
+	expected := []string{"config.yaml", "drafts/keep.yaml", "entities/cities.csv", "nested/build/config.yaml"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("Expected files %v, got %v", expected, names)
+	}
+}
+
+// chunkWriter records the sizes of the writes.
+type chunkWriter struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestStream(t *testing.T) {
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("helsinki\n"), upload.ChunkSize/4)
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("templates: ''"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "entities"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "entities", "cities.csv"), large, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ud := upload.CreateTarFromDir(dir)
+
+	var w chunkWriter
+	var progress bytes.Buffer
+	n, checksum, err := ud.Stream(&w, &progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(w.Len()) {
+		t.Errorf("Expected %d bytes written, got %d", w.Len(), n)
+	}
+	for _, chunk := range w.chunks {
+		if chunk > upload.ChunkSize {
+			t.Errorf("Expected chunks of at most %d bytes, got %d", upload.ChunkSize, chunk)
+		}
+	}
+	if int64(progress.Len()) != ud.Size {
+		t.Errorf("Expected progress of %d bytes, got %d", ud.Size, progress.Len())
+	}
+	sum := sha256.Sum256(w.Bytes())
+	if checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected checksum %x, got %s", sum, checksum)
+	}
+
+	tr := tar.NewReader(&w.Buffer)
+	contents := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents[hdr.Name], err = io.ReadAll(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(contents) != 2 || string(contents["config.yaml"]) != "templates: ''" || !bytes.Equal(contents["entities/cities.csv"], large) {
+		t.Errorf("Unexpected tar contents: %d files", len(contents))
+	}
+}